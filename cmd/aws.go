@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/jwmatthews/overlook/pkg/overlook"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// NewEC2Client returns the EC2 API used to sample a region with its calls recorded in stats, replace it
+// to run watch against a fake EC2
+var NewEC2Client = func(sess client.ConfigProvider, region string, stats *overlook.CallStats) ec2iface.EC2API {
+	var svc *ec2.EC2
+	if region == "" {
+		svc = ec2.New(sess)
+	} else {
+		svc = ec2.New(sess, aws.NewConfig().WithRegion(region))
+	}
+	retryPolicy.Instrument(svc.Client, stats)
+	return svc
+}
+
+// spotPriceWindow is how far back spot prices are collected, covering the hour since the previous sample
+const spotPriceWindow = time.Hour
+
+// RegionClients are the AWS APIs used to sample a region
+type RegionClients struct {
+	EC2   ec2iface.EC2API
+	ELB   elbiface.ELBAPI
+	ELBV2 elbv2iface.ELBV2API
+	RDS   rdsiface.RDSAPI
+	// Stats records the calls made by the clients, every request is retried following retryPolicy
+	Stats *overlook.CallStats
+}
+
+// NewRegionClients returns the clients used to sample a region, replace it to run watch against fakes
+var NewRegionClients = func(sess client.ConfigProvider, region string) RegionClients {
+	cfg := aws.NewConfig().WithRegion(region)
+	stats := &overlook.CallStats{}
+	elbSvc := elb.New(sess, cfg)
+	retryPolicy.Instrument(elbSvc.Client, stats)
+	elbv2Svc := elbv2.New(sess, cfg)
+	retryPolicy.Instrument(elbv2Svc.Client, stats)
+	rdsSvc := rds.New(sess, cfg)
+	retryPolicy.Instrument(rdsSvc.Client, stats)
+	return RegionClients{
+		EC2:   NewEC2Client(sess, region, stats),
+		ELB:   elbSvc,
+		ELBV2: elbv2Svc,
+		RDS:   rdsSvc,
+		Stats: stats,
+	}
+}
+
+// GetRegions returns a slice of all region strings
+func GetRegions(ctx aws.Context, svc ec2iface.EC2API) ([]string, error) {
+	resultRegions, err := svc.DescribeRegionsWithContext(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var regions = make([]string, 0)
+	for _, r := range resultRegions.Regions {
+		regions = append(regions, *r.RegionName)
+	}
+	return regions, nil
+}
+
+// GetAccounts returns the accounts listed in the config file
+func GetAccounts() []overlook.Account {
+	var accounts []overlook.Account
+	if err := viper.UnmarshalKey("accounts", &accounts); err != nil {
+		log.Fatalln("Unable to parse accounts from config", err)
+	}
+	return accounts
+}
+
+// GetAccountID returns the ID of the account the session's credentials belong to, the call is
+// retried following retryPolicy and recorded in stats
+func GetAccountID(ctx aws.Context, sess client.ConfigProvider, stats *overlook.CallStats) (string, error) {
+	svc := sts.New(sess)
+	retryPolicy.Instrument(svc.Client, stats)
+	identity, err := svc.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return *identity.Account, nil
+}
+
+// accountSession returns a session for the account, assuming its role if one is configured
+func accountSession(sess *session.Session, account overlook.Account) *session.Session {
+	if account.RoleArn == "" {
+		return sess
+	}
+	creds := stscreds.NewCredentials(sess, account.RoleArn)
+	return sess.Copy(aws.NewConfig().WithCredentials(creds))
+}
+
+// processRegion collects a region, a failure only affects this region and is recorded in its status.
+// Services without a client, such as those not present in a recording, are skipped.
+func processRegion(ctx aws.Context, clients RegionClients, accountID string, region string) overlook.RegionInfo {
+	fmt.Println("Processing region: ", region, "in account: ", accountID)
+	var rInfo overlook.RegionInfo
+	rInfo.Provider = overlook.ProviderAWS
+	rInfo.AccountID = accountID
+	rInfo.RegionName = region
+	rInfo.Status = overlook.RegionStatusOK
+	defer func() {
+		if clients.Stats != nil {
+			rInfo.Stats = *clients.Stats
+		}
+		log.Infoln("AWS calls for region: ", region, "in account: ", accountID, rInfo.Stats)
+	}()
+
+	svc := clients.EC2
+	instances, err := overlook.GetInstances(ctx, svc, region)
+	if err != nil {
+		log.Errorln("Unable to collect instances in region: ", region, "in account: ", accountID, err)
+		rInfo.Status = overlook.RegionStatusFailed
+		rInfo.Err = err
+		return rInfo
+	}
+	rInfo.Instances = instances
+	err = overlook.CollectSpotPriceHistory(ctx, svc, instances, overlook.Now().Add(-spotPriceWindow))
+	if err != nil {
+		log.Errorln("Unable to collect spot prices in region: ", region, "in account: ", accountID, err)
+		rInfo.Status = overlook.RegionStatusPartial
+		rInfo.Err = err
+	}
+	rInfo.Cost = overlook.CalculateCost(instances)
+	rInfo.TypeSummary = overlook.CreateInstanceTypeSummary(instances)
+	rInfo.BillingSnapshots = overlook.FormBillingSnapshots(instances)
+
+	volumes, err := overlook.GetVolumes(ctx, svc, region)
+	if err != nil {
+		log.Errorln("Unable to collect volumes in region: ", region, "in account: ", accountID, err)
+		rInfo.Status = overlook.RegionStatusPartial
+		rInfo.Err = err
+	}
+	rInfo.Volumes = volumes
+	rInfo.VolumeCost = overlook.CalculateVolumeCost(volumes)
+	rInfo.VolumeTypeSummary = overlook.CreateVolumeTypeSummary(volumes)
+	rInfo.BillingSnapshots = append(rInfo.BillingSnapshots, overlook.FormVolumeSnapshots(volumes)...)
+
+	network := make([]overlook.NetworkResourceInfo, 0)
+	addresses, err := overlook.GetAddresses(ctx, svc, region)
+	if err != nil {
+		log.Errorln("Unable to collect elastic IPs in region: ", region, "in account: ", accountID, err)
+		rInfo.Status = overlook.RegionStatusPartial
+		rInfo.Err = err
+	}
+	network = append(network, addresses...)
+	natGateways, err := overlook.GetNatGateways(ctx, svc, region)
+	if err != nil {
+		log.Errorln("Unable to collect NAT gateways in region: ", region, "in account: ", accountID, err)
+		rInfo.Status = overlook.RegionStatusPartial
+		rInfo.Err = err
+	}
+	network = append(network, natGateways...)
+	if clients.ELB != nil && clients.ELBV2 != nil {
+		loadBalancers, err := overlook.GetLoadBalancers(ctx, clients.ELB, clients.ELBV2, region, accountID)
+		if err != nil {
+			log.Errorln("Unable to collect load balancers in region: ", region, "in account: ", accountID, err)
+			rInfo.Status = overlook.RegionStatusPartial
+			rInfo.Err = err
+		}
+		network = append(network, loadBalancers...)
+	}
+	rInfo.Network = network
+	rInfo.NetworkCost = overlook.CalculateNetworkCost(network)
+	rInfo.NetworkSummary = overlook.CreateNetworkSummary(network)
+	rInfo.BillingSnapshots = append(rInfo.BillingSnapshots, overlook.FormNetworkSnapshots(network)...)
+
+	var dbInstances []overlook.DBInstanceInfo
+	if clients.RDS != nil {
+		dbInstances, err = overlook.GetDBInstances(ctx, clients.RDS, region)
+		if err != nil {
+			log.Errorln("Unable to collect RDS instances in region: ", region, "in account: ", accountID, err)
+			rInfo.Status = overlook.RegionStatusPartial
+			rInfo.Err = err
+		}
+	}
+	rInfo.DBInstances = dbInstances
+	rInfo.DBCost = overlook.CalculateDBCost(dbInstances)
+	rInfo.DBSummary = overlook.CreateDBInstanceClassSummary(dbInstances)
+	rInfo.BillingSnapshots = append(rInfo.BillingSnapshots, overlook.FormDBSnapshots(dbInstances)...)
+	for i := range rInfo.BillingSnapshots {
+		rInfo.BillingSnapshots[i].AccountID = accountID
+	}
+	log.Infoln("Completed processing region: ", region, "in account: ", accountID, "status: ", rInfo.Status)
+	return rInfo
+}
+
+// awsCollector samples every region of every configured AWS account, or replays a recording of them
+type awsCollector struct {
+	sess      *session.Session
+	replayDir string
+	clients   map[overlook.Target]RegionClients
+	// failures are the accounts whose regions could not be listed, keyed by their target for every region
+	failures map[overlook.Target]error
+}
+
+// newAWSCollector returns a collector using the shared config credentials
+func newAWSCollector() (overlook.Collector, error) {
+	// Load session from shared config
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &awsCollector{sess: sess, clients: make(map[overlook.Target]RegionClients), failures: make(map[overlook.Target]error)}, nil
+}
+
+// newReplayCollector returns a collector answering from the EC2 responses recorded in dir
+func newReplayCollector(dir string) overlook.Collector {
+	return &awsCollector{replayDir: dir, clients: make(map[overlook.Target]RegionClients), failures: make(map[overlook.Target]error)}
+}
+
+// Provider is always AWS
+func (c *awsCollector) Provider() string {
+	return overlook.ProviderAWS
+}
+
+// Targets returns every region of every configured account, or of every recorded account when replaying
+func (c *awsCollector) Targets(ctx aws.Context) ([]overlook.Target, error) {
+	if c.replayDir != "" {
+		return c.replayTargets(ctx)
+	}
+	if recordDir != "" {
+		if err := overlook.WriteRecordingInfo(recordDir, overlook.Now()); err != nil {
+			return nil, fmt.Errorf("unable to write recording to %s: %v", recordDir, err)
+		}
+	}
+
+	accounts := GetAccounts()
+	if len(accounts) == 0 {
+		// Default to the account of the shared config credentials
+		accounts = append(accounts, overlook.Account{Name: "default"})
+	}
+
+	targets := make([]overlook.Target, 0)
+	for _, a := range accounts {
+		acctSess := accountSession(c.sess, a)
+		var stats overlook.CallStats
+		accountID := a.ID
+		if accountID == "" {
+			var err error
+			accountID, err = GetAccountID(ctx, acctSess, &stats)
+			if err != nil {
+				log.Errorln("Skipping account", a.Name, "unable to determine account ID:", err)
+				// Without its ID the account is recorded under its name
+				targets = append(targets, c.addFailedTarget(a.Name, fmt.Errorf("unable to determine account ID: %v", err)))
+				continue
+			}
+		}
+
+		regions := make([]string, 0)
+		if region != "" {
+			regions = append(regions, region)
+		} else {
+			var err error
+			regions, err = GetRegions(ctx, recordEC2(NewEC2Client(acctSess, "", &stats), accountID, ""))
+			if err != nil {
+				log.Errorln("Skipping account", a.Name, accountID, "unable to list regions:", err)
+				targets = append(targets, c.addFailedTarget(accountID, fmt.Errorf("unable to list regions: %v", err)))
+				continue
+			}
+		}
+		log.Infoln("Working with account", a.Name, accountID, "and", len(regions), "regions: ", regions)
+
+		for _, r := range regions {
+			clients := NewRegionClients(acctSess, r)
+			clients.EC2 = recordEC2(clients.EC2, accountID, r)
+			targets = append(targets, c.addTarget(accountID, r, clients))
+		}
+	}
+	return targets, nil
+}
+
+// replayTargets returns every recorded region of every recorded account
+func (c *awsCollector) replayTargets(ctx aws.Context) ([]overlook.Target, error) {
+	accountIDs, err := overlook.RecordedAccounts(c.replayDir)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]overlook.Target, 0)
+	for _, accountID := range accountIDs {
+		regions := make([]string, 0)
+		if region != "" {
+			regions = append(regions, region)
+		} else {
+			regions, err = GetRegions(ctx, overlook.NewReplayEC2(overlook.RecordingDir(c.replayDir, accountID, "")))
+			if err != nil {
+				// Sampled with --region, only the regions that were sampled are recorded
+				regions, err = overlook.RecordedRegions(c.replayDir, accountID)
+				if err != nil {
+					log.Errorln("Skipping account", accountID, "unable to read recorded regions:", err)
+					targets = append(targets, c.addFailedTarget(accountID, fmt.Errorf("unable to read recorded regions: %v", err)))
+					continue
+				}
+			}
+		}
+		log.Infoln("Replaying account", accountID, "and", len(regions), "regions: ", regions)
+
+		for _, r := range regions {
+			clients := RegionClients{EC2: overlook.NewReplayEC2(overlook.RecordingDir(c.replayDir, accountID, r))}
+			targets = append(targets, c.addTarget(accountID, r, clients))
+		}
+	}
+	return targets, nil
+}
+
+func (c *awsCollector) addTarget(accountID string, region string, clients RegionClients) overlook.Target {
+	t := overlook.Target{Provider: overlook.ProviderAWS, AccountID: accountID, Region: region}
+	c.clients[t] = clients
+	return t
+}
+
+// addFailedTarget returns a target for every region of an account whose regions could not be listed,
+// collecting it records the failure in the sample
+func (c *awsCollector) addFailedTarget(accountID string, err error) overlook.Target {
+	t := overlook.Target{Provider: overlook.ProviderAWS, AccountID: accountID, Region: overlook.AllRegions}
+	c.failures[t] = err
+	return t
+}
+
+// Collect samples a region with the clients created for it by Targets
+func (c *awsCollector) Collect(ctx aws.Context, target overlook.Target) overlook.RegionInfo {
+	if err, ok := c.failures[target]; ok {
+		var rInfo overlook.RegionInfo
+		rInfo.Provider = overlook.ProviderAWS
+		rInfo.AccountID = target.AccountID
+		rInfo.RegionName = target.Region
+		rInfo.Status = overlook.RegionStatusFailed
+		rInfo.Err = err
+		return rInfo
+	}
+	return processRegion(ctx, c.clients[target], target.AccountID, target.Region)
+}
+
+// recordEC2 wraps svc to save its responses when running with --record
+func recordEC2(svc ec2iface.EC2API, accountID string, region string) ec2iface.EC2API {
+	if recordDir == "" {
+		return svc
+	}
+	return overlook.NewRecordingEC2(svc, overlook.RecordingDir(recordDir, accountID, region))
+}
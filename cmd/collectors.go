@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/jwmatthews/overlook/pkg/overlook"
+	log "github.com/sirupsen/logrus"
+	"sync"
+)
+
+// collectorFactories create the collector of each provider watch can sample, keyed by provider name
+var collectorFactories = map[string]func() (overlook.Collector, error){
+	overlook.ProviderAWS:       newAWSCollector,
+	overlook.ProviderOpenStack: newOpenStackCollector,
+}
+
+// GetCollectors returns a collector for each of the named providers
+func GetCollectors(names []string) ([]overlook.Collector, error) {
+	collectors := make([]overlook.Collector, 0)
+	for _, name := range names {
+		factory, ok := collectorFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown provider: %s", name)
+		}
+		c, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("unable to create %s collector: %v", name, err)
+		}
+		collectors = append(collectors, c)
+	}
+	return collectors, nil
+}
+
+// collectorTarget is a target along with the collector that samples it
+type collectorTarget struct {
+	collector overlook.Collector
+	target    overlook.Target
+}
+
+// runCollectors samples every target of every collector and stores the snapshots of all of them together
+func runCollectors(ctx context.Context, collectors []overlook.Collector) {
+	targets := make([]collectorTarget, 0)
+	for _, c := range collectors {
+		cTargets, err := c.Targets(ctx)
+		if err != nil {
+			log.Errorln("Skipping provider", c.Provider(), "unable to list targets:", err)
+			continue
+		}
+		for _, t := range cTargets {
+			targets = append(targets, collectorTarget{collector: c, target: t})
+		}
+	}
+
+	var runningTotal float64
+	var consumerGroup sync.WaitGroup
+	var producerGroup sync.WaitGroup
+	var regionInfoChannel = make(chan overlook.RegionInfo, 3)
+	var targetChannel = make(chan collectorTarget)
+
+	// Consumer:  Will aggregate all the info
+	consumerGroup.Add(1)
+	go func() {
+		defer consumerGroup.Done()
+		runningTotal, _ = aggregateAllInfo(regionInfoChannel)
+	}()
+
+	// Producer: A bounded pool of workers each processing one region of an account at a time
+	workers := concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		producerGroup.Add(1)
+		go func(targets <-chan collectorTarget, c chan<- overlook.RegionInfo) {
+			defer producerGroup.Done()
+			for t := range targets {
+				c <- t.collector.Collect(ctx, t.target)
+			}
+		}(targetChannel, regionInfoChannel)
+	}
+	for _, t := range targets {
+		targetChannel <- t
+	}
+	close(targetChannel)
+	producerGroup.Wait()
+	close(regionInfoChannel)
+	//
+	// Now we wait for consumer to complete
+	//
+	consumerGroup.Wait()
+
+	formattedTotal := fmt.Sprintf("%.2f", runningTotal)
+	log.Infoln("RunningTotal: ", formattedTotal)
+}
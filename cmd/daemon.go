@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// NextSampleTime returns the next wall-clock boundary of interval after now, delayed by up to jitter
+func NextSampleTime(now time.Time, interval time.Duration, jitter time.Duration) time.Time {
+	next := now.Truncate(interval).Add(interval)
+	if jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(jitter))))
+	}
+	return next
+}
+
+// RunDaemon invokes sample on every interval boundary until SIGINT or SIGTERM is received.
+// A tick is skipped if the previous sample is still running, and on shutdown we wait for
+// the in-flight sample so its snapshot is flushed to disk. A failed sample is logged and
+// sampling carries on at the next boundary.
+func RunDaemon(interval time.Duration, jitter time.Duration, sample func() error) {
+	rand.Seed(time.Now().UnixNano())
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	var inFlight sync.WaitGroup
+	var running int32
+
+	next := NextSampleTime(time.Now(), interval, jitter)
+	log.Infoln("Running as daemon with interval", interval, "next sample at", next)
+	timer := time.NewTimer(time.Until(next))
+	for {
+		select {
+		case sig := <-stop:
+			log.Infoln("Received", sig, "waiting for in-flight sample to complete")
+			timer.Stop()
+			inFlight.Wait()
+			log.Infoln("Daemon stopped")
+			return
+		case <-timer.C:
+			if atomic.CompareAndSwapInt32(&running, 0, 1) {
+				inFlight.Add(1)
+				go func() {
+					defer inFlight.Done()
+					defer atomic.StoreInt32(&running, 0)
+					if err := sample(); err != nil {
+						log.Errorln("Sample failed, trying again at the next interval:", err)
+					}
+				}()
+			} else {
+				log.Warnln("Previous sample is still running, skipping this tick")
+			}
+			next = NextSampleTime(time.Now(), interval, jitter)
+			log.Infoln("Next sample at", next)
+			timer.Reset(time.Until(next))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextSampleTimeAlignsToBoundary(t *testing.T) {
+	tests := []struct {
+		now      time.Time
+		interval time.Duration
+		expected time.Time
+	}{
+		{time.Date(2021, 3, 1, 10, 17, 23, 0, time.UTC), time.Hour, time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{time.Date(2021, 3, 1, 10, 17, 23, 0, time.UTC), 15 * time.Minute, time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC)},
+		// A sample due now is already being taken, the next one is a whole interval away
+		{time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC), 15 * time.Minute, time.Date(2021, 3, 1, 10, 45, 0, 0, time.UTC)},
+		{time.Date(2021, 3, 1, 23, 59, 59, 0, time.UTC), time.Hour, time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		if next := NextSampleTime(tc.now, tc.interval, 0); !next.Equal(tc.expected) {
+			t.Errorf("expected the sample after %v every %v at %v, got %v", tc.now, tc.interval, tc.expected, next)
+		}
+	}
+}
+
+func TestNextSampleTimeJitterBounds(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 17, 23, 0, time.UTC)
+	boundary := time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC)
+	jitter := 30 * time.Second
+
+	delayed := false
+	for i := 0; i < 1000; i++ {
+		next := NextSampleTime(now, time.Hour, jitter)
+		if next.Before(boundary) || !next.Before(boundary.Add(jitter)) {
+			t.Fatalf("expected the sample within %v after %v, got %v", jitter, boundary, next)
+		}
+		delayed = delayed || next.After(boundary)
+	}
+	if !delayed {
+		t.Error("expected some samples to be delayed by the jitter")
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunDaemonKeepsSamplingAfterFailedSample(t *testing.T) {
+	var samples int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunDaemon(10*time.Millisecond, 0, func() error {
+			if atomic.AddInt32(&samples, 1) == 3 {
+				// Stops the daemon the way a service manager would
+				if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+					t.Error(err)
+				}
+			}
+			return errors.New("unable to create aws collector")
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the daemon to stop on SIGTERM")
+	}
+	if n := atomic.LoadInt32(&samples); n != 3 {
+		t.Errorf("expected sampling to go on after failed samples until stopped, got %d samples", n)
+	}
+}
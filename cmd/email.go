@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -34,19 +33,12 @@ const (
 	CharSet = "UTF-8"
 )
 
-func EmailReport() {
-
-	usageFileNames := overlook.GetBillingDataSortedFileNames()
-	log.Infoln(usageFileNames)
+func init() {
+	addReportFlags(EmailCommand)
+}
 
-	reports := make([]overlook.ReportDaily, 0)
-	for _, f := range usageFileNames {
-		fmt.Println("Processing: ", f)
-		dailyEntry := overlook.ReadSnapshotInfo(f)
-		r := overlook.GetReport(dailyEntry)
-		reports = append(reports, r)
-	}
-	SendEmail(reports)
+func EmailReport() {
+	SendEmail(dailyReports())
 }
 
 func SendEmail(reports []overlook.ReportDaily) {
@@ -54,8 +46,7 @@ func SendEmail(reports []overlook.ReportDaily) {
 	for _, r := range reports {
 		body = body + r.FormatByCost() + "\n"
 	}
-	now := time.Now()
-	ymd := now.Format("01-02-2006")
+	ymd := overlook.DayOf(time.Now())
 
 	subject := "Migration Eng AWS Usage for " + ymd
 
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jwmatthews/overlook/pkg/overlook"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// MigrateCommand cobra command to invoke Migrate
+var MigrateCommand = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate stored snapshots to UTC days",
+	Long: `Migrate the snapshots stored in days named MM-DD-YYYY with local hours to days named YYYY-MM-DD
+with UTC hours. Every sample is kept, moved to the UTC day and hour it was taken in. Run it with the
+same time zone the snapshots were taken in.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		Migrate()
+	},
+}
+
+// Migrate converts the legacy days of the snapshot store to UTC days
+func Migrate() {
+	log.Infoln("Migrate invoked")
+	migrated, err := overlook.MigrateSnapshots(snapshotStore())
+	if err != nil {
+		log.Fatalln("Unable to migrate snapshots", err)
+	}
+	fmt.Println("Migrated", migrated, "days of snapshots")
+	log.Infoln("Migrated", migrated, "days of snapshots")
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/jwmatthews/overlook/pkg/overlook"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"net/http"
+)
+
+// GetOpenStackConfig returns the "openstack" section of the config file
+func GetOpenStackConfig() overlook.OpenStackConfig {
+	var cfg overlook.OpenStackConfig
+	if err := viper.UnmarshalKey("openstack", &cfg); err != nil {
+		log.Fatalln("Unable to parse openstack from config", err)
+	}
+	return cfg
+}
+
+// loadChargebackRates makes the configured OpenStack flavor rates available to watch and report
+func loadChargebackRates() {
+	if !viper.IsSet("openstack") {
+		return
+	}
+	overlook.SetChargebackRates(overlook.ProviderOpenStack, GetOpenStackConfig().Rates)
+}
+
+// openstackCollector samples the servers of every configured project in every region
+type openstackCollector struct {
+	cfg        overlook.OpenStackConfig
+	httpClient *http.Client
+	clients    map[overlook.Target]overlook.NovaClient
+}
+
+func newOpenStackCollector() (overlook.Collector, error) {
+	cfg := GetOpenStackConfig()
+	if cfg.AuthURL == "" && cfg.ComputeURL == "" {
+		return nil, fmt.Errorf("openstack.auth_url is not set in the config file")
+	}
+	if len(cfg.Projects) == 0 {
+		return nil, fmt.Errorf("openstack.projects is empty in the config file")
+	}
+	return &openstackCollector{
+		cfg:        cfg,
+		httpClient: &http.Client{},
+		clients:    make(map[overlook.Target]overlook.NovaClient),
+	}, nil
+}
+
+// Provider is always OpenStack
+func (c *openstackCollector) Provider() string {
+	return overlook.ProviderOpenStack
+}
+
+// Targets authenticates to every project and returns each region it has a compute endpoint in
+func (c *openstackCollector) Targets(ctx context.Context) ([]overlook.Target, error) {
+	targets := make([]overlook.Target, 0)
+	for _, p := range c.cfg.Projects {
+		token, err := c.authenticate(ctx, p)
+		if err != nil {
+			log.Errorln("Skipping project", p.Name, p.ID, "unable to authenticate:", err)
+			continue
+		}
+
+		regions := make([]string, 0)
+		switch {
+		case region != "":
+			regions = append(regions, region)
+		case len(c.cfg.Regions) > 0:
+			regions = append(regions, c.cfg.Regions...)
+		default:
+			for r := range token.ComputeEndpoints {
+				regions = append(regions, r)
+			}
+		}
+		log.Infoln("Working with project", token.ProjectName, token.ProjectID, "and", len(regions), "regions: ", regions)
+
+		for _, r := range regions {
+			endpoint := c.cfg.ComputeURL
+			if endpoint == "" {
+				var ok bool
+				if endpoint, ok = token.ComputeEndpoints[r]; !ok {
+					log.Errorln("Skipping region", r, "of project", token.ProjectName, "no compute endpoint in the service catalog")
+					continue
+				}
+			}
+			t := overlook.Target{Provider: overlook.ProviderOpenStack, AccountID: token.ProjectID, Region: r}
+			c.clients[t] = overlook.NovaClient{HTTPClient: c.httpClient, Endpoint: endpoint, Token: token.Token}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
+
+// authenticate returns a token scoped to the project. Without an auth_url there is no Keystone
+// to ask, which is only useful with compute_url pointing at a fake Nova API that ignores the token.
+func (c *openstackCollector) authenticate(ctx context.Context, p overlook.OpenStackProject) (overlook.KeystoneToken, error) {
+	if c.cfg.AuthURL == "" {
+		projectID := p.ID
+		if projectID == "" {
+			projectID = p.Name
+		}
+		return overlook.KeystoneToken{ProjectID: projectID, ProjectName: p.Name}, nil
+	}
+	var token overlook.KeystoneToken
+	var stats overlook.CallStats
+	err := retryPolicy.Do(ctx, &stats, func(ctx context.Context) (err error) {
+		token, err = overlook.AuthenticateOpenStack(ctx, c.httpClient, c.cfg, p)
+		return err
+	})
+	return token, err
+}
+
+// Collect samples the servers of a project in a region
+func (c *openstackCollector) Collect(ctx context.Context, target overlook.Target) overlook.RegionInfo {
+	fmt.Println("Processing region: ", target.Region, "in project: ", target.AccountID)
+	var rInfo overlook.RegionInfo
+	rInfo.Provider = overlook.ProviderOpenStack
+	rInfo.AccountID = target.AccountID
+	rInfo.RegionName = target.Region
+	rInfo.Status = overlook.RegionStatusOK
+	defer func() {
+		log.Infoln("OpenStack calls for region: ", target.Region, "in project: ", target.AccountID, rInfo.Stats)
+	}()
+
+	var instances []overlook.InstanceInfo
+	err := retryPolicy.Do(ctx, &rInfo.Stats, func(ctx context.Context) (err error) {
+		instances, err = overlook.GetOpenStackInstances(ctx, c.clients[target], target.Region)
+		return err
+	})
+	if err != nil {
+		log.Errorln("Unable to collect servers in region: ", target.Region, "in project: ", target.AccountID, err)
+		rInfo.Status = overlook.RegionStatusFailed
+		rInfo.Err = err
+		return rInfo
+	}
+	rInfo.Instances = instances
+	rInfo.Cost = overlook.CalculateCost(instances)
+	rInfo.TypeSummary = overlook.CreateInstanceTypeSummary(instances)
+	rInfo.BillingSnapshots = overlook.FormBillingSnapshots(instances)
+	for i := range rInfo.BillingSnapshots {
+		rInfo.BillingSnapshots[i].AccountID = target.AccountID
+	}
+	log.Infoln("Completed processing region: ", target.Region, "in project: ", target.AccountID, "status: ", rInfo.Status)
+	return rInfo
+}
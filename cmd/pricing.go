@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jwmatthews/overlook/pkg/overlook"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// PricingCommand cobra command to manage the local price cache
+var PricingCommand = &cobra.Command{
+	Use:   "pricing",
+	Short: "Manage the local cache of EC2 prices",
+	Long:  `Manage the local cache of EC2 on-demand prices imported from the AWS Price List bulk offer files.`,
+}
+
+// PricingImportCommand cobra command to invoke PricingImport
+var PricingImportCommand = &cobra.Command{
+	Use:   "import <offer-file.json>",
+	Short: "Import an AWS Price List offer file for EC2",
+	Long: `Import an AWS Price List bulk offer file for EC2, such as
+https://pricing.us-east-1.amazonaws.com/offers/v1.0/aws/AmazonEC2/current/us-east-1/index.json,
+as a new version of the price cache and make it the current version.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		PricingImport(args[0])
+	},
+}
+
+// PricingListCommand cobra command to invoke PricingList
+var PricingListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List the versions in the price cache",
+	Long:  `List the versions in the price cache, marking the current version`,
+	Run: func(cmd *cobra.Command, args []string) {
+		PricingList()
+	},
+}
+
+// PricingUseCommand cobra command to invoke PricingUse
+var PricingUseCommand = &cobra.Command{
+	Use:   "use <version>",
+	Short: "Make a version of the price cache current",
+	Long:  `Make a version of the price cache current`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		PricingUse(args[0])
+	},
+}
+
+// PricingImportSpotCommand cobra command to invoke PricingImportSpot
+var PricingImportSpotCommand = &cobra.Command{
+	Use:   "import-spot <spot-price-history.json>",
+	Short: "Import spot price history",
+	Long: `Import spot price history saved from aws ec2 describe-spot-price-history, used to price
+spot instances when watch could not collect spot prices itself.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		PricingImportSpot(args[0])
+	},
+}
+
+func init() {
+	PricingCommand.AddCommand(PricingImportCommand)
+	PricingCommand.AddCommand(PricingImportSpotCommand)
+	PricingCommand.AddCommand(PricingListCommand)
+	PricingCommand.AddCommand(PricingUseCommand)
+}
+
+// PricingImport imports an offer file into the price cache
+func PricingImport(offerFileName string) {
+	log.Infoln("Importing offer file", offerFileName)
+	pricingDir := overlook.GetPricingDataLocation()
+	f, err := os.Open(offerFileName)
+	if err != nil {
+		fmt.Println("Unable to open offer file:", err)
+		os.Exit(1)
+	}
+	defer overlook.CheckClose(f)
+
+	priceList, err := overlook.ImportOfferFile(f)
+	if err != nil {
+		fmt.Println("Unable to import offer file:", err)
+		os.Exit(1)
+	}
+	if err := overlook.WritePriceList(pricingDir, priceList); err != nil {
+		fmt.Println("Unable to write price cache:", err)
+		os.Exit(1)
+	}
+	if err := overlook.SetCurrentPriceListVersion(pricingDir, priceList.Version); err != nil {
+		fmt.Println("Unable to write price cache:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Imported", len(priceList.Prices), "prices as version", priceList.Version)
+	log.Infoln("Imported", len(priceList.Prices), "prices as version", priceList.Version)
+}
+
+// PricingImportSpot adds saved spot price history to the price cache
+func PricingImportSpot(fileName string) {
+	log.Infoln("Importing spot price history", fileName)
+	pricingDir := overlook.GetPricingDataLocation()
+	f, err := os.Open(fileName)
+	if err != nil {
+		fmt.Println("Unable to open spot price history:", err)
+		os.Exit(1)
+	}
+	defer overlook.CheckClose(f)
+
+	history, err := overlook.ReadSpotPriceHistory(pricingDir)
+	if err != nil {
+		history = overlook.NewSpotPriceHistory()
+	}
+	added, err := overlook.ImportSpotPriceHistory(f, history)
+	if err != nil {
+		fmt.Println("Unable to import spot price history:", err)
+		os.Exit(1)
+	}
+	if err := overlook.WriteSpotPriceHistory(pricingDir, history); err != nil {
+		fmt.Println("Unable to write price cache:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Imported", added, "spot prices")
+	log.Infoln("Imported", added, "spot prices")
+}
+
+// PricingList prints the versions in the price cache
+func PricingList() {
+	pricingDir := overlook.GetPricingDataLocation()
+	versions, err := overlook.PriceListVersions(pricingDir)
+	if err != nil {
+		fmt.Println("No price cache in", pricingDir)
+		return
+	}
+	current, _ := overlook.CurrentPriceListVersion(pricingDir)
+	for _, v := range versions {
+		if v == current {
+			fmt.Println("*", v)
+		} else {
+			fmt.Println(" ", v)
+		}
+	}
+}
+
+// PricingUse makes a version of the price cache current
+func PricingUse(version string) {
+	if err := overlook.SetCurrentPriceListVersion(overlook.GetPricingDataLocation(), version); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// loadPriceList makes GetCostPerHour use the current version of the price cache, if there is one,
+// and spot instances priced from the cached spot price history
+func loadPriceList() {
+	pricingDir := overlook.GetPricingDataLocation()
+	if history, err := overlook.ReadSpotPriceHistory(pricingDir); err == nil {
+		overlook.UseSpotPriceHistory(history)
+	}
+	version, err := overlook.CurrentPriceListVersion(pricingDir)
+	if err != nil {
+		return
+	}
+	priceList, err := overlook.ReadPriceList(pricingDir, version)
+	if err != nil {
+		log.Errorln("Unable to load price list", version, "using built-in prices:", err)
+		return
+	}
+	log.Infoln("Using price list", version)
+	overlook.UsePriceList(priceList)
+}
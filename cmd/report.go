@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/jwmatthews/overlook/pkg/overlook"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"time"
 )
 
 // ReportCommand cobra command to invoke Report
@@ -16,15 +20,90 @@ var ReportCommand = &cobra.Command{
 	},
 }
 
-func Report() {
-	log.Infoln("Running report")
-	usageFileNames := overlook.GetBillingDataSortedFileNames()
-	log.Infoln(usageFileNames)
+// Flags choosing the snapshots and pricing of reports
+var pricingMode string
+var reportFrom string
+var reportTo string
+var reportFilter overlook.SnapshotFilter
+
+func init() {
+	addReportFlags(ReportCommand)
+}
 
-	for _, f := range usageFileNames {
-		log.Infoln("Processing: ", f)
-		dailyEntry := overlook.ReadSnapshotInfo(f)
+// addReportFlags adds the flags choosing the snapshots and pricing of reports to cmd
+func addReportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&pricingMode, "pricing", overlook.PricingModeCurrent,
+		"Price instances at the rate \"recorded\" in the snapshots or from the \"current\" price list")
+	cmd.Flags().StringVar(&reportFrom, "from", "", "First day to report on, as YYYY-MM-DD")
+	cmd.Flags().StringVar(&reportTo, "to", "", "Last day to report on, as YYYY-MM-DD")
+	cmd.Flags().StringVar(&reportFilter.Account, "account", "", "Only report on resources of this account")
+	cmd.Flags().StringVar(&reportFilter.Region, "region", "", "Only report on resources in this region")
+	cmd.Flags().StringVar(&reportFilter.InstanceType, "instance-type", "", "Only report on instances of this type")
+	cmd.Flags().StringVar(&reportFilter.State, "state", "", "Only report on resources in this state")
+}
+
+// parseDateFlag returns the day given to a date flag, the zero time when the flag is not set
+func parseDateFlag(name string, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	day, err := time.ParseInLocation(overlook.DayLayout, value, time.UTC)
+	if err != nil {
+		fmt.Println("Unable to parse", name+", expected YYYY-MM-DD:", err)
+		os.Exit(1)
+	}
+	return day
+}
+
+// dailyReports returns the report of every day in the snapshot store selected by the report flags, most recent first
+func dailyReports() []overlook.ReportDaily {
+	if err := overlook.SetReportPricingMode(pricingMode); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	filter := reportFilter
+	filter.From = parseDateFlag("--from", reportFrom)
+	filter.To = parseDateFlag("--to", reportTo)
+
+	entries, err := overlook.QuerySnapshots(snapshotStore(), filter)
+	if err != nil {
+		log.Fatalln("Unable to read snapshots", err)
+	}
+	reports := make([]overlook.ReportDaily, 0)
+	for _, dailyEntry := range entries {
 		r := overlook.GetReport(dailyEntry)
+		log.Infoln("Processed: ", r.Date)
+		overlook.WarnUnpriced(r.UnpricedInstanceTypes)
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+func Report() {
+	log.Infoln("Running report")
+	for _, r := range dailyReports() {
 		overlook.PrintReport(r)
 	}
 }
+
+// loadReservations applies the Reserved Instances and Savings Plans listed in the config file to reports
+func loadReservations() {
+	var reservations []overlook.Reservation
+	if err := viper.UnmarshalKey("reservations", &reservations); err != nil {
+		log.Fatalln("Unable to parse reservations from config", err)
+	}
+	if err := overlook.SetReservations(reservations); err != nil {
+		log.Fatalln("Invalid reservations in config", err)
+	}
+}
+
+// loadPricingConfig applies the price overrides, discounts and currency of the config file to reports
+func loadPricingConfig() {
+	var cfg overlook.PricingConfig
+	if err := viper.UnmarshalKey("pricing", &cfg); err != nil {
+		log.Fatalln("Unable to parse pricing from config", err)
+	}
+	if err := overlook.SetPricingConfig(cfg); err != nil {
+		log.Fatalln("Invalid pricing in config", err)
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jwmatthews/overlook/pkg/overlook"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// RepriceCommand cobra command to invoke Reprice
+var RepriceCommand = &cobra.Command{
+	Use:   "reprice",
+	Short: "Reprice stored snapshots against a version of the price cache",
+	Long: `Reprice the instances in the stored snapshots of every day from --from through --to against a
+version of the price cache, the current version by default, recording the version used in each snapshot.
+Reports run with --pricing recorded then use these rates.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		Reprice(repriceFrom, repriceTo, repriceVersion)
+	},
+}
+
+var repriceFrom string
+var repriceTo string
+var repriceVersion string
+
+func init() {
+	RepriceCommand.Flags().StringVar(&repriceFrom, "from", "", "First day to reprice, as YYYY-MM-DD")
+	RepriceCommand.Flags().StringVar(&repriceTo, "to", "", "Last day to reprice, as YYYY-MM-DD, defaults to --from")
+	RepriceCommand.Flags().StringVar(&repriceVersion, "version", "", "Version of the price cache to reprice against, defaults to the current version")
+}
+
+// Reprice rewrites the snapshots of the days from through to with prices from a version of the price cache
+func Reprice(from string, to string, version string) {
+	if to == "" {
+		to = from
+	}
+	if from == "" {
+		fmt.Println("--from is required")
+		os.Exit(1)
+	}
+	fromDay := parseDateFlag("--from", from)
+	toDay := parseDateFlag("--to", to)
+	if toDay.Before(fromDay) {
+		fmt.Println("--to must not be before --from")
+		os.Exit(1)
+	}
+
+	if version != "" {
+		priceList, err := overlook.ReadPriceList(overlook.GetPricingDataLocation(), version)
+		if err != nil {
+			fmt.Println("Unable to load price list", version, err)
+			os.Exit(1)
+		}
+		overlook.UsePriceList(priceList)
+	}
+	version = overlook.ActivePriceListVersion()
+	log.Infoln("Repricing snapshots from", from, "to", to, "with price list", version)
+
+	repriced, err := overlook.RepriceSnapshots(snapshotStore(), fromDay, toDay)
+	if err != nil {
+		log.Fatalln("Unable to write repriced snapshots", err)
+	}
+	fmt.Println("Repriced", repriced, "snapshots with price list", version)
+	log.Infoln("Repriced", repriced, "snapshots with price list", version)
+}
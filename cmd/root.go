@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	homedir "github.com/mitchellh/go-homedir"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"os"
 )
 
 const logFileName = "overlook.log"
 
+var cfgFile string
+
 var rootCmd = &cobra.Command{
 	Use:   "overlook",
 	Short: "Overlook samples EC2 usage and creates reports of usage and costs",
@@ -43,14 +47,43 @@ func init() {
 	//log.SetOutput(mw)
 
 	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./.overlook.yaml or $HOME/.overlook.yaml)")
+	rootCmd.PersistentFlags().StringVar(&storeType, "store", "", "where snapshots are kept, file, s3 or sqlite (default is store.type of the config file, or file)")
 	rootCmd.AddCommand(WatchCommand)
 	rootCmd.AddCommand(ReportCommand)
 	rootCmd.AddCommand(EmailCommand)
 	rootCmd.AddCommand(SpreadSheetCommand)
+	rootCmd.AddCommand(PricingCommand)
+	rootCmd.AddCommand(RepriceCommand)
+	rootCmd.AddCommand(MigrateCommand)
 
 	log.Infoln("Starting")
 }
 
 func initConfig() {
-	//
+	if cfgFile != "" {
+		viper.SetConfigFile(cfgFile)
+	} else {
+		home, err := homedir.Dir()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		viper.AddConfigPath(".")
+		viper.AddConfigPath(home)
+		viper.SetConfigName(".overlook")
+	}
+	viper.SetEnvPrefix("overlook")
+	viper.AutomaticEnv()
+
+	if err := viper.ReadInConfig(); err == nil {
+		log.Infoln("Using config file:", viper.ConfigFileUsed())
+	} else if cfgFile != "" {
+		fmt.Println("Unable to read config file:", cfgFile, err)
+		os.Exit(1)
+	}
+	loadPriceList()
+	loadChargebackRates()
+	loadReservations()
+	loadPricingConfig()
 }
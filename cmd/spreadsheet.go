@@ -16,20 +16,14 @@ var SpreadSheetCommand = &cobra.Command{
 	},
 }
 
+func init() {
+	addReportFlags(SpreadSheetCommand)
+}
+
 func SpreadSheet() {
 	log.Infoln("Running spreadsheet")
+	for _, r := range dailyReports() {
+		overlook.PrintReport(r)
+	}
 	overlook.RunSpreadsheet()
-	/*
-		usageFileNames := overlook.GetBillingDataSortedFileNames()
-		log.Infoln(usageFileNames)
-
-
-		for _, f := range usageFileNames {
-			log.Infoln("Processing: ", f)
-			dailyEntry := overlook.ReadSnapshotInfo(f)
-			r := overlook.GetReport(dailyEntry)
-			overlook.PrintReport(r)
-		}
-	*/
-
 }
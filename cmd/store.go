@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jwmatthews/overlook/pkg/overlook"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// storeType is the --store flag, it replaces the type of store in the config file
+var storeType string
+
+// GetStoreConfig returns the snapshot store configured in the config file and with --store
+func GetStoreConfig() overlook.StoreConfig {
+	var cfg overlook.StoreConfig
+	if err := viper.UnmarshalKey("store", &cfg); err != nil {
+		log.Fatalln("Unable to parse store from config", err)
+	}
+	if storeType != "" {
+		cfg.Type = storeType
+	}
+	if cfg.Type == "" {
+		cfg.Type = overlook.StoreTypeFile
+	}
+	return cfg
+}
+
+// NewSnapshotStore returns the snapshot store described by cfg
+func NewSnapshotStore(cfg overlook.StoreConfig) (overlook.SnapshotStore, error) {
+	switch cfg.Type {
+	case overlook.StoreTypeFile:
+		dir := cfg.Dir
+		if dir == "" {
+			dir = overlook.GetBillingDataLocation()
+		}
+		store := overlook.NewFileSnapshotStore(dir)
+		store.Nested = cfg.Nested
+		return store, nil
+	case overlook.StoreTypeS3:
+		if cfg.Bucket == "" {
+			return nil, fmt.Errorf("store.bucket is needed for the s3 store")
+		}
+		awsCfg := aws.NewConfig()
+		if cfg.Region != "" {
+			awsCfg = awsCfg.WithRegion(cfg.Region)
+		}
+		if cfg.Endpoint != "" {
+			// S3-compatible servers such as MinIO are addressed by path rather than bucket host names
+			awsCfg = awsCfg.WithEndpoint(cfg.Endpoint).WithS3ForcePathStyle(true)
+		}
+		sess, err := session.NewSession(awsCfg)
+		if err != nil {
+			return nil, err
+		}
+		store := overlook.NewS3SnapshotStore(s3.New(sess), cfg.Bucket, cfg.Prefix)
+		store.Nested = cfg.Nested
+		return store, nil
+	case overlook.StoreTypeSQLite:
+		path := cfg.Path
+		if path == "" {
+			path = overlook.GetSQLiteDataLocation()
+		}
+		return overlook.OpenSQLiteSnapshotStore(path)
+	}
+	return nil, fmt.Errorf("unknown store type %q, expected %s, %s or %s", cfg.Type,
+		overlook.StoreTypeFile, overlook.StoreTypeS3, overlook.StoreTypeSQLite)
+}
+
+// snapshotStore returns the configured snapshot store, exiting when it can not be used
+func snapshotStore() overlook.SnapshotStore {
+	store, err := NewSnapshotStore(GetStoreConfig())
+	if err != nil {
+		log.Fatalln("Unable to open snapshot store", err)
+	}
+	return store
+}
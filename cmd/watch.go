@@ -1,134 +1,128 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/client"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/jwmatthews/overlook/pkg/overlook"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"os"
-	"sync"
+	"time"
 )
 
 // WatchCommand cobra command to invoke Watch
 var WatchCommand = &cobra.Command{
 	Use:   "watch",
 	Short: "Watches ec2 usage",
-	Long:  `Watches ec2 usage, sampling at a given interval and recording usage info.`,
+	Long:  `Watches ec2 usage, and the usage of other cloud providers, sampling at a given interval and recording usage info.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		Watch()
 	},
 }
 
 var region string
+var providers []string
+var daemon bool
+var interval time.Duration
+var jitter time.Duration
+var concurrency int
+var retryPolicy = overlook.DefaultRetryPolicy
+var recordDir string
+var replayDir string
+var replayAt string
 
 func init() {
 	WatchCommand.Flags().StringVarP(&region, "region", "r", "", "Specify a single region, by default will assume all regions")
-}
-
-// GetRegions returns a slice of all region strings
-func GetRegions(sess client.ConfigProvider) []string {
-	svc := ec2.New(sess)
-	resultRegions, err := svc.DescribeRegions(nil)
-	if err != nil {
-		fmt.Println("Error", err)
-		os.Exit(1)
-	}
-	var regions = make([]string, 0)
-	for _, r := range resultRegions.Regions {
-		regions = append(regions, *r.RegionName)
-	}
-	return regions
+	WatchCommand.Flags().StringSliceVar(&providers, "provider", []string{overlook.ProviderAWS}, "Cloud providers to sample, may be repeated or comma separated")
+	WatchCommand.Flags().BoolVar(&daemon, "daemon", false, "Keep running and take a sample every interval")
+	WatchCommand.Flags().DurationVar(&interval, "interval", time.Hour, "Sampling interval, recorded with each sample so reports know how long it stands for. With --daemon samples are taken every interval, aligned to wall-clock boundaries")
+	WatchCommand.Flags().DurationVar(&jitter, "jitter", 30*time.Second, "Maximum random delay added to each scheduled sample")
+	WatchCommand.Flags().IntVar(&concurrency, "concurrency", 5, "Maximum number of regions processed at once")
+	WatchCommand.Flags().IntVar(&retryPolicy.MaxRetries, "max-retries", overlook.DefaultRetryPolicy.MaxRetries, "Number of times a throttled or failed AWS call is retried")
+	WatchCommand.Flags().DurationVar(&retryPolicy.BaseDelay, "retry-delay", overlook.DefaultRetryPolicy.BaseDelay, "Initial backoff between retries, doubled on every retry")
+	WatchCommand.Flags().DurationVar(&retryPolicy.CallTimeout, "call-timeout", overlook.DefaultRetryPolicy.CallTimeout, "Timeout for each attempt of an AWS call")
+	WatchCommand.Flags().StringVar(&recordDir, "record", "", "Save the raw EC2 responses of each sample to this directory")
+	WatchCommand.Flags().StringVar(&replayDir, "replay", "", "Sample from EC2 responses saved with --record instead of calling AWS")
+	WatchCommand.Flags().StringVar(&replayAt, "at", "", "Time the replayed sample is taken at in RFC3339, defaults to when it was recorded")
 }
 
 func aggregateAllInfo(c <-chan overlook.RegionInfo) (float64, []overlook.RegionInfo) {
-	var billingDir = overlook.GetBillingDataLocation()
 	var runningTotal float64
 	var regionInfo = make([]overlook.RegionInfo, 0)
 	for rInfo := range c {
 		regionInfo = append(regionInfo, rInfo)
-		runningTotal += rInfo.Cost
+		runningTotal += rInfo.Cost + rInfo.VolumeCost + rInfo.NetworkCost + rInfo.DBCost
 	}
 	overlook.DisplayRegionInfo(regionInfo)
-	overlook.StoreBillingSnapshots(regionInfo, billingDir)
+	overlook.WarnUnpriced(overlook.UnpricedInstanceTypes(regionInfo))
+	if err := overlook.StoreBillingSnapshots(regionInfo, snapshotStore(), interval); err != nil {
+		log.Errorln("Unable to store billing snapshots", err)
+	}
 	return runningTotal, regionInfo
 }
 
-func processRegion(sess client.ConfigProvider, region string) overlook.RegionInfo {
-	fmt.Println("Processing region: ", region)
-	var rInfo overlook.RegionInfo
-	rInfo.RegionName = region
-
-	svc := ec2.New(sess, aws.NewConfig().WithRegion(rInfo.RegionName))
-	instances, err := overlook.GetInstances(svc)
-	if err != nil {
-		log.Fatalln("Error", err)
-		os.Exit(1)
+// Watch takes a single sample, or when running with --daemon keeps sampling every interval
+func Watch() {
+	log.Infoln("Watch invoked")
+	if replayDir != "" {
+		if daemon || recordDir != "" {
+			fmt.Println("--replay can not be combined with --daemon or --record")
+			os.Exit(1)
+		}
+		Replay(replayDir, replayAt)
+		return
 	}
-	rInfo.Instances = instances
-	rInfo.Cost, err = overlook.CalculateCost(instances)
-	if err != nil {
-		log.Errorln("Unable to calculate costs for all instances")
-		log.Errorln(err)
+	if daemon {
+		if interval <= 0 {
+			fmt.Println("Interval must be greater than zero")
+			os.Exit(1)
+		}
+		RunDaemon(interval, jitter, Sample)
+		return
+	}
+	if err := Sample(); err != nil {
+		log.Fatalln(err)
 	}
-	rInfo.TypeSummary = overlook.CreateInstanceTypeSummary(instances)
-	rInfo.BillingSnapshots = overlook.FormBillingSnapshots(instances)
-	log.Infoln("Completed processing region: ", region)
-	return rInfo
 }
 
 //
-// Will walk through all regions and gather a report of
+// Will walk through all regions of every account of every provider and gather a report of
 //	- Instances
 //			By type:  Number of instances with uptime of each, total hours up
-//	- Volumes: TODO
-func Watch() {
-	log.Infoln("Watch invoked")
-	// Load session from shared config
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-
-	regions := make([]string, 0)
-	if region != "" {
-		regions = append(regions, region)
-	} else {
-		regions = GetRegions(sess)
+//	- Volumes
+//			By type:  Number of volumes, how many are unattached, total GB and cost per hour
+//	- Elastic IPs, NAT gateways and load balancers
+//			By type:  Number of resources, how many are idle and cost per hour
+//	- RDS DB instances
+//			By class:  Number of DB instances with uptime of each, total hours up
+func Sample() error {
+	log.Infoln("Sample invoked")
+	collectors, err := GetCollectors(providers)
+	if err != nil {
+		return err
 	}
+	runCollectors(context.Background(), collectors)
+	overlook.StoreSpotPriceHistory(overlook.GetPricingDataLocation())
+	return nil
+}
 
-	log.Infoln("Working with ", len(regions), "regions: ", regions)
-
-	var runningTotal float64
-	var consumerGroup sync.WaitGroup
-	var producerGroup sync.WaitGroup
-	var regionInfoChannel = make(chan overlook.RegionInfo, 3)
-
-	// Consumer:  Will aggregate all the info
-	consumerGroup.Add(1)
-	go func() {
-		defer consumerGroup.Done()
-		runningTotal, _ = aggregateAllInfo(regionInfoChannel)
-	}()
-
-	// Producer: Create a goroutine per region to produce info
-	for _, r := range regions {
-		producerGroup.Add(1)
-		go func(sess *session.Session, reg string, c chan<- overlook.RegionInfo) {
-			defer producerGroup.Done()
-			rInfo := processRegion(sess, reg)
-			c <- rInfo
-		}(sess, r, regionInfoChannel)
+// Replay takes a sample from the EC2 responses recorded in dir as if they were live,
+// the clock is pinned to at, or to the time of the recording when at is empty
+func Replay(dir string, at string) {
+	log.Infoln("Replay invoked for recording", dir)
+	info, err := overlook.ReadRecordingInfo(dir)
+	if err != nil {
+		log.Fatalln("Unable to read recording from", dir, err)
 	}
-	producerGroup.Wait()
-	close(regionInfoChannel)
-	//
-	// Now we wait for consumer to complete
-	//
-	consumerGroup.Wait()
-
-	formattedTotal := fmt.Sprintf("%.2f", runningTotal)
-	log.Infoln("RunningTotal: ", formattedTotal)
+	sampleTime := info.RecordedAt
+	if at != "" {
+		sampleTime, err = time.Parse(time.RFC3339, at)
+		if err != nil {
+			log.Fatalln("Unable to parse --at, expected RFC3339 such as 2006-01-02T15:04:05Z", err)
+		}
+	}
+	overlook.Now = func() time.Time { return sampleTime }
+	log.Infoln("Replaying sample at", sampleTime)
+	runCollectors(context.Background(), []overlook.Collector{newReplayCollector(dir)})
+	overlook.StoreSpotPriceHistory(overlook.GetPricingDataLocation())
 }
@@ -0,0 +1,14 @@
+package overlook
+
+// UnknownAccount is used for snapshots recorded before the account ID was captured
+const UnknownAccount = "unknown"
+
+// Account describes an AWS account to sample, read from the "accounts" list in the config file
+type Account struct {
+	// Name is a friendly name used in logs
+	Name string `mapstructure:"name"`
+	// ID is the 12 digit account ID, when empty it is looked up with the assumed role
+	ID string `mapstructure:"id"`
+	// RoleArn is the IAM role assumed to sample the account, when empty the shared config credentials are used
+	RoleArn string `mapstructure:"role_arn"`
+}
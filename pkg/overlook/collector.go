@@ -0,0 +1,58 @@
+package overlook
+
+import (
+	"context"
+	"fmt"
+)
+
+// Cloud providers a Collector can sample, snapshots written before Provider was recorded are all AWS
+const (
+	ProviderAWS       = "aws"
+	ProviderOpenStack = "openstack"
+)
+
+// Instance states shared by every provider, collectors map the states of their
+// provider onto these so reports treat all instances alike
+const (
+	InstanceStatePending    = "pending"
+	InstanceStateRunning    = "running"
+	InstanceStateStopped    = "stopped"
+	InstanceStateTerminated = "terminated"
+)
+
+// Target is a single region of an account to sample, providers without
+// accounts use the equivalent such as an OpenStack project
+type Target struct {
+	Provider  string
+	AccountID string
+	Region    string
+}
+
+// Collector samples the resources of a cloud provider one target at a time.
+// Collect is called concurrently for different targets once Targets has returned.
+type Collector interface {
+	// Provider is the name of the cloud provider recorded with every snapshot
+	Provider() string
+	// Targets returns every region of every account the collector samples
+	Targets(ctx context.Context) ([]Target, error)
+	// Collect samples a target, failures are recorded in the status of the RegionInfo
+	Collect(ctx context.Context, target Target) RegionInfo
+}
+
+// GetInstanceCostPerHour returns the cost per hour of an instance of a provider and the source of the
+// price, chargeback rates only depend on the instance type
+func GetInstanceCostPerHour(provider string, key PriceKey, availabilityZone string) (float64, string, error) {
+	switch provider {
+	case "", ProviderAWS:
+		return GetCostPerHour(key, availabilityZone)
+	}
+	rates, ok := chargebackRates[provider]
+	if !ok {
+		return 0.0, "", fmt.Errorf("unknown provider: %s", provider)
+	}
+	cost := rates[key.InstanceType]
+	if cost == 0 {
+		return 0.0, "", fmt.Errorf("no chargeback rate for %s instance type: %s", provider, key.InstanceType)
+	}
+	return cost, PriceSourceChargeback, nil
+}
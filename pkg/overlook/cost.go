@@ -1,9 +1,37 @@
 package overlook
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
+// costPerHour is the built-in Linux on-demand rate of instance types in DefaultRegion
 var costPerHour map[string]float64
 
+// hoursPerMonth is what AWS uses to convert monthly storage prices to hourly
+const hoursPerMonth = 730
+
+var costPerGBMonth map[string]float64
+var costPerIopsMonth map[string]float64
+
+// networkCostPerHour is keyed by networkCategory.
+// Only the hourly charge is tracked, data processed and LCU charges are not sampled.
+var networkCostPerHour map[string]float64
+
+// dbCostPerHour is the Single-AZ MySQL and PostgreSQL rate of an RDS DB instance class,
+// Multi-AZ deployments are billed twice the rate for both compute and storage
+var dbCostPerHour map[string]float64
+
+// dbStorageCostPerGBMonth is the Single-AZ general purpose storage rate of RDS
+const dbStorageCostPerGBMonth = 0.115
+
+// chargebackRates are the internal hourly rates of the instance types of providers
+// without a public price list, keyed by provider and then instance type
+var chargebackRates = make(map[string]map[string]float64)
+
+// freeIops is the number of provisioned IOPS included in the storage price
+var freeIops map[string]int64
+
 func init() {
 	//https://aws.amazon.com/ec2/pricing/on-demand/
 	costPerHour = make(map[string]float64)
@@ -21,13 +49,204 @@ func init() {
 	costPerHour["t2.large"] = 0.0928
 	costPerHour["t3a.xlarge"] = 0.1504
 	costPerHour["t3a.2xlarge"] = 0.3008
+
+	//https://aws.amazon.com/ebs/pricing/
+	costPerGBMonth = make(map[string]float64)
+	costPerGBMonth["gp2"] = 0.10
+	costPerGBMonth["gp3"] = 0.08
+	costPerGBMonth["io1"] = 0.125
+	costPerGBMonth["io2"] = 0.125
+	costPerGBMonth["st1"] = 0.045
+	costPerGBMonth["sc1"] = 0.015
+	costPerGBMonth["standard"] = 0.05
+
+	costPerIopsMonth = make(map[string]float64)
+	costPerIopsMonth["gp3"] = 0.005
+	costPerIopsMonth["io1"] = 0.065
+	costPerIopsMonth["io2"] = 0.065
+
+	freeIops = make(map[string]int64)
+	freeIops["gp3"] = 3000
+
+	//https://aws.amazon.com/vpc/pricing/
+	//https://aws.amazon.com/elasticloadbalancing/pricing/
+	networkCostPerHour = make(map[string]float64)
+	networkCostPerHour[ResourceTypeElasticIP] = 0.005
+	networkCostPerHour[ResourceTypeNatGateway] = 0.045
+	networkCostPerHour[networkCategory(ResourceTypeLoadBalancer, LoadBalancerTypeClassic)] = 0.025
+	networkCostPerHour[networkCategory(ResourceTypeLoadBalancer, LoadBalancerTypeApplication)] = 0.0225
+	networkCostPerHour[networkCategory(ResourceTypeLoadBalancer, LoadBalancerTypeNetwork)] = 0.0225
+	networkCostPerHour[networkCategory(ResourceTypeLoadBalancer, LoadBalancerTypeGateway)] = 0.0125
+
+	//https://aws.amazon.com/rds/mysql/pricing/
+	dbCostPerHour = make(map[string]float64)
+	dbCostPerHour["db.t2.micro"] = 0.017
+	dbCostPerHour["db.t2.small"] = 0.034
+	dbCostPerHour["db.t2.medium"] = 0.068
+	dbCostPerHour["db.t2.large"] = 0.136
+	dbCostPerHour["db.t3.micro"] = 0.017
+	dbCostPerHour["db.t3.small"] = 0.034
+	dbCostPerHour["db.t3.medium"] = 0.068
+	dbCostPerHour["db.t3.large"] = 0.136
+	dbCostPerHour["db.m4.large"] = 0.175
+	dbCostPerHour["db.m4.xlarge"] = 0.35
+	dbCostPerHour["db.m5.large"] = 0.171
+	dbCostPerHour["db.m5.xlarge"] = 0.342
+	dbCostPerHour["db.m5.2xlarge"] = 0.684
+	dbCostPerHour["db.r5.large"] = 0.24
+	dbCostPerHour["db.r5.xlarge"] = 0.48
 }
 
-// GetCostPerHour returns cost per hour based in instanceType
-func GetCostPerHour(instanceType string) (float64, error) {
-	cost := costPerHour[instanceType]
+// Sources the rate of an instance is taken from
+const (
+	PriceSourceBuiltin    = "built-in"
+	PriceSourcePriceList  = "price-list"
+	PriceSourceSpot       = "spot"
+	PriceSourceChargeback = "chargeback"
+	// PriceSourceRecorded is the rate recorded in a snapshot when it was sampled or repriced
+	PriceSourceRecorded = "recorded"
+)
+
+// sourcePriceListVersion returns the version of the price list a rate from source was taken from,
+// empty when it was not taken from a price list
+func sourcePriceListVersion(source string) string {
+	switch source {
+	case PriceSourcePriceList:
+		return ActivePriceListVersion()
+	case PriceSourceBuiltin:
+		return BuiltinPriceListVersion
+	}
+	return ""
+}
+
+// GetCostPerHour returns cost per hour of an instance, priced by its type, operating system and tenancy
+// in the region, and availability zone, it runs in, along with the source of the price. Prices are looked
+// up in the imported price list, instances in a Local Zone are priced at the rates of the zone. Prices
+// missing from the price list fall back to the built-in rates for shared Linux instances in DefaultRegion
+// only, other instances are left unpriced rather than priced without their licenses or dedicated hosts.
+func GetCostPerHour(key PriceKey, availabilityZone string) (float64, string, error) {
+	if key.Region == "" {
+		key.Region = DefaultRegion
+	}
+	if key.OperatingSystem == "" {
+		key.OperatingSystem = DefaultOperatingSystem
+	}
+	if key.Tenancy == "" {
+		key.Tenancy = DefaultTenancy
+	}
+	locations := priceLocations(key.Region, availabilityZone)
+	if activePriceList != nil {
+		for _, location := range locations {
+			locationKey := key
+			locationKey.Region = location
+			if cost, ok := activePriceList.Lookup(locationKey); ok {
+				return cost, PriceSourcePriceList, nil
+			}
+		}
+	}
+	cost := costPerHour[key.InstanceType]
 	if cost == 0 {
-		return 0.0, fmt.Errorf("unknown instance type: %s", instanceType)
+		return 0.0, "", fmt.Errorf("unknown instance type: %s", key.InstanceType)
+	}
+	if locations[0] != DefaultRegion {
+		return 0.0, "", fmt.Errorf("no price for %s in %s, import a price list with overlook pricing import", key.InstanceType, locations[0])
+	}
+	if key.OperatingSystem != DefaultOperatingSystem || key.Tenancy != DefaultTenancy {
+		return 0.0, "", fmt.Errorf("no price for %s, import a price list with overlook pricing import", key)
+	}
+	return cost, PriceSourceBuiltin, nil
+}
+
+// priceLocations returns the locations an instance is priced at, the Local Zone it runs in before its region
+func priceLocations(region string, availabilityZone string) []string {
+	zoneGroup := strings.TrimRight(availabilityZone, "abcdefghijklmnopqrstuvwxyz")
+	if zoneGroup != "" && zoneGroup != region {
+		return []string{zoneGroup, region}
+	}
+	return []string{region}
+}
+
+// SetChargebackRates replaces the hourly rates of the instance types of a provider
+func SetChargebackRates(provider string, rates []ChargebackRate) {
+	providerRates := make(map[string]float64)
+	for _, r := range rates {
+		providerRates[r.Flavor] = r.Rate
+	}
+	chargebackRates[provider] = providerRates
+}
+
+// checkBuiltinRegion returns an error for a region other than DefaultRegion, the only region the
+// built-in rates of volumes, networking resources and DB instances are for
+func checkBuiltinRegion(region string) error {
+	if region != "" && region != DefaultRegion {
+		return fmt.Errorf("no price in %s, only the rates of %s are built in", region, DefaultRegion)
+	}
+	return nil
+}
+
+// GetVolumeCostPerHour returns the hourly cost of an EBS volume in a region based on its type, size and
+// provisioned IOPS
+func GetVolumeCostPerHour(region string, volumeType string, sizeGB int64, iops int64) (float64, error) {
+	gbMonth, ok := costPerGBMonth[volumeType]
+	if !ok {
+		return 0.0, fmt.Errorf("unknown volume type: %s", volumeType)
+	}
+	if err := checkBuiltinRegion(region); err != nil {
+		return 0.0, err
+	}
+	monthly := gbMonth * float64(sizeGB)
+	if iopsMonth, ok := costPerIopsMonth[volumeType]; ok && iops > freeIops[volumeType] {
+		monthly += iopsMonth * float64(iops-freeIops[volumeType])
+	}
+	return monthly / hoursPerMonth, nil
+}
+
+// GetNetworkCostPerHour returns the hourly cost of an Elastic IP, NAT gateway or load balancer in a region
+func GetNetworkCostPerHour(region string, resourceType string, loadBalancerType string) (float64, error) {
+	key := networkCategory(resourceType, loadBalancerType)
+	cost, ok := networkCostPerHour[key]
+	if !ok {
+		return 0.0, fmt.Errorf("unknown network resource type: %s", key)
+	}
+	if err := checkBuiltinRegion(region); err != nil {
+		return 0.0, err
+	}
+	return cost, nil
+}
+
+// networkCategory is the resource type, qualified by the load balancer type for load balancers
+func networkCategory(resourceType string, loadBalancerType string) string {
+	if resourceType == ResourceTypeLoadBalancer {
+		return resourceType + "/" + loadBalancerType
+	}
+	return resourceType
+}
+
+// GetDBCostPerHour returns the hourly cost of an RDS DB instance in a region including its allocated storage
+func GetDBCostPerHour(region string, dbInstanceClass string, multiAZ bool, allocatedStorageGB int64) (float64, error) {
+	cost, ok := dbCostPerHour[dbInstanceClass]
+	if !ok {
+		return 0.0, fmt.Errorf("unknown DB instance class: %s", dbInstanceClass)
+	}
+	storageCost, err := GetDBStorageCostPerHour(region, multiAZ, allocatedStorageGB)
+	if err != nil {
+		return 0.0, err
+	}
+	if multiAZ {
+		cost = cost * 2
+	}
+	return cost + storageCost, nil
+}
+
+// GetDBStorageCostPerHour returns the hourly cost of the storage allocated to an RDS DB instance in a region,
+// which is all that is billed while the DB instance is stopped
+func GetDBStorageCostPerHour(region string, multiAZ bool, allocatedStorageGB int64) (float64, error) {
+	if err := checkBuiltinRegion(region); err != nil {
+		return 0.0, err
+	}
+	cost := dbStorageCostPerGBMonth * float64(allocatedStorageGB) / hoursPerMonth
+	if multiAZ {
+		cost = cost * 2
 	}
 	return cost, nil
 }
@@ -0,0 +1,73 @@
+package overlook
+
+import "testing"
+
+// usePriceList makes GetCostPerHour look prices up in p until the test ends
+func usePriceList(t *testing.T, p *PriceList) {
+	previous := activePriceList
+	UsePriceList(p)
+	t.Cleanup(func() { UsePriceList(previous) })
+}
+
+func TestGetCostPerHourBuiltinRatesOnlyInDefaultRegion(t *testing.T) {
+	usePriceList(t, nil)
+
+	cost, source, err := GetCostPerHour(PriceKey{Region: DefaultRegion, InstanceType: "m5.large"}, "us-east-1a")
+	if err != nil || cost != 0.096 || source != PriceSourceBuiltin {
+		t.Errorf("expected the built-in rate in %s, got %v %q %v", DefaultRegion, cost, source, err)
+	}
+	for _, tc := range []struct{ region, zone string }{
+		{"eu-west-1", "eu-west-1a"},
+		{DefaultRegion, "us-east-1-bos-1a"},
+	} {
+		if cost, _, err := GetCostPerHour(PriceKey{Region: tc.region, InstanceType: "m5.large"}, tc.zone); err == nil {
+			t.Errorf("expected an instance in %s to be unpriced without a price list, got %v", tc.zone, cost)
+		}
+	}
+}
+
+func TestGetCostPerHourFromPriceList(t *testing.T) {
+	usePriceList(t, &PriceList{Version: "1", Prices: map[string]float64{
+		PriceKey{"eu-west-1", "m5.large", DefaultOperatingSystem, DefaultTenancy}.String():       0.107,
+		PriceKey{"us-east-1-bos-1", "m5.large", DefaultOperatingSystem, DefaultTenancy}.String(): 0.12,
+	}})
+
+	tests := []struct {
+		region, zone string
+		expected     float64
+		source       string
+	}{
+		{"eu-west-1", "eu-west-1a", 0.107, PriceSourcePriceList},
+		{DefaultRegion, "us-east-1-bos-1a", 0.12, PriceSourcePriceList},
+		// Missing from the price list
+		{DefaultRegion, "us-east-1a", 0.096, PriceSourceBuiltin},
+	}
+	for _, tc := range tests {
+		cost, source, err := GetCostPerHour(PriceKey{Region: tc.region, InstanceType: "m5.large"}, tc.zone)
+		if err != nil || cost != tc.expected || source != tc.source {
+			t.Errorf("expected %v from %s in %s, got %v %q %v", tc.expected, tc.source, tc.zone, cost, source, err)
+		}
+	}
+	if cost, _, err := GetCostPerHour(PriceKey{Region: "ap-south-1", InstanceType: "m5.large"}, "ap-south-1a"); err == nil {
+		t.Errorf("expected a region missing from the price list to be unpriced, got %v", cost)
+	}
+}
+
+func TestGetCostPerHourWithoutOperatingSystemPrice(t *testing.T) {
+	usePriceList(t, &PriceList{Version: "1", Prices: map[string]float64{
+		PriceKey{DefaultRegion, "m5.large", "RHEL", DefaultTenancy}.String(): 0.156,
+	}})
+
+	cost, _, err := GetCostPerHour(PriceKey{Region: DefaultRegion, InstanceType: "m5.large", OperatingSystem: "RHEL"}, "us-east-1a")
+	if err != nil || cost != 0.156 {
+		t.Errorf("expected the RHEL rate, got %v %v", cost, err)
+	}
+	for _, key := range []PriceKey{
+		{Region: DefaultRegion, InstanceType: "m5.large", OperatingSystem: "Windows"},
+		{Region: DefaultRegion, InstanceType: "m5.large", Tenancy: "Dedicated"},
+	} {
+		if cost, _, err := GetCostPerHour(key, "us-east-1a"); err == nil {
+			t.Errorf("expected %s to be unpriced rather than priced at the Linux rate, got %v", key, cost)
+		}
+	}
+}
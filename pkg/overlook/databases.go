@@ -0,0 +1,133 @@
+package overlook
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	log "github.com/sirupsen/logrus"
+)
+
+// dbStatusStopped is the RDS status of a stopped DB instance, only its storage is billed
+const dbStatusStopped = "stopped"
+
+// GetDBInstances returns a list of RDS DB instances in the region ordered by HoursUp
+func GetDBInstances(ctx aws.Context, svc rdsiface.RDSAPI, region string) ([]DBInstanceInfo, error) {
+	dbInstances := make([]DBInstanceInfo, 0)
+	err := svc.DescribeDBInstancesPagesWithContext(ctx, &rds.DescribeDBInstancesInput{},
+		func(page *rds.DescribeDBInstancesOutput, lastPage bool) bool {
+			for _, db := range page.DBInstances {
+				var info DBInstanceInfo
+				info.ID = *db.DBInstanceIdentifier
+				info.Arn = aws.StringValue(db.DBInstanceArn)
+				info.DBInstanceClass = *db.DBInstanceClass
+				info.Engine = aws.StringValue(db.Engine)
+				info.MultiAZ = aws.BoolValue(db.MultiAZ)
+				info.AllocatedStorage = aws.Int64Value(db.AllocatedStorage)
+				info.StorageType = aws.StringValue(db.StorageType)
+				info.State = aws.StringValue(db.DBInstanceStatus)
+				info.AvailabilityZone = aws.StringValue(db.AvailabilityZone)
+				info.Region = region
+				if db.InstanceCreateTime != nil && info.State != dbStatusStopped {
+					info.HoursUp = hoursSince(*db.InstanceCreateTime)
+				}
+				dbInstances = append(dbInstances, info)
+			}
+			return true
+		})
+	if err != nil {
+		log.Infoln("Error", err)
+		return nil, err
+	}
+
+	// Tags are not part of DescribeDBInstances, a DB instance whose tags can not be listed is kept without them
+	for i, info := range dbInstances {
+		if info.Arn == "" {
+			continue
+		}
+		result, err := svc.ListTagsForResourceWithContext(ctx, &rds.ListTagsForResourceInput{ResourceName: aws.String(info.Arn)})
+		if err != nil {
+			log.Warnln("Unable to list tags of DB instance", info.ID, "in region", region, err)
+			continue
+		}
+		var tags string
+		for _, t := range result.TagList {
+			tags += fmt.Sprintf("%s:%s ", *t.Key, *t.Value)
+		}
+		dbInstances[i].Tags = tags
+	}
+	//
+	// Sort by HoursUp
+	//
+	sort.Slice(dbInstances, func(i, j int) bool { return dbInstances[i].HoursUp > dbInstances[j].HoursUp })
+	return dbInstances, nil
+}
+
+// CalculateDBCost calculates cost of current DB instances since they were created, DB instances no
+// price is known for are left out
+func CalculateDBCost(dbInstances []DBInstanceInfo) float64 {
+	runningTotal := 0.0
+	for _, db := range dbInstances {
+		cost, err := GetDBCostPerHour(db.Region, db.DBInstanceClass, db.MultiAZ, db.AllocatedStorage)
+		if err != nil {
+			continue
+		}
+		runningTotal += cost * db.HoursUp
+	}
+	return runningTotal
+}
+
+// CreateDBInstanceClassSummary creates summary info on DB instance classes
+func CreateDBInstanceClassSummary(dbInstances []DBInstanceInfo) map[string]DBInstanceClassSummary {
+	var summary = make(map[string]DBInstanceClassSummary)
+	for _, db := range dbInstances {
+		dbSumm := summary[db.DBInstanceClass]
+		dbSumm.DBInstanceClass = db.DBInstanceClass
+		dbSumm.NumberOfInstances++
+		dbSumm.TotalHours += db.HoursUp
+		costPerHour, err := GetDBCostPerHour(db.Region, db.DBInstanceClass, db.MultiAZ, db.AllocatedStorage)
+		if err != nil {
+			dbSumm.NumberUnpriced++
+			summary[db.DBInstanceClass] = dbSumm
+			continue
+		}
+		dbSumm.Cost += costPerHour * db.HoursUp
+		summary[db.DBInstanceClass] = dbSumm
+	}
+	return summary
+}
+
+// FormDBSnapshots forms billing snapshots for DB instances so they are stored next to EC2 instances
+func FormDBSnapshots(dbInstances []DBInstanceInfo) []BillingSnapshot {
+	var billSnaps = make([]BillingSnapshot, 0)
+	for _, db := range dbInstances {
+		b := BillingSnapshot{}
+		costPerHour, err := GetDBCostPerHour(db.Region, db.DBInstanceClass, db.MultiAZ, db.AllocatedStorage)
+		if err != nil {
+			b.Unpriced = true
+		}
+		// DB instance identifiers are only unique within an account and region, unlike their ARN
+		b.ID = db.Arn
+		if b.ID == "" {
+			b.ID = db.ID
+		}
+		b.ResourceType = ResourceTypeDBInstance
+		b.DBInstanceClass = db.DBInstanceClass
+		b.Engine = db.Engine
+		b.MultiAZ = db.MultiAZ
+		b.SizeGB = db.AllocatedStorage
+		b.VolumeType = db.StorageType
+		b.CostPerHour = costPerHour
+		b.CurrentCost = costPerHour * db.HoursUp
+		b.HoursUp = db.HoursUp
+		b.Tags = db.Tags
+		b.State = db.State
+		b.AvailabilityZone = db.AvailabilityZone
+		b.Region = db.Region
+		b.Arn = db.Arn
+		billSnaps = append(billSnaps, b)
+	}
+	return billSnaps
+}
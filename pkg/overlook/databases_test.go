@@ -0,0 +1,125 @@
+package overlook
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// fakeRDS answers DescribeDBInstances with a single page and ListTagsForResource by ARN
+type fakeRDS struct {
+	rdsiface.RDSAPI
+	dbInstances []*rds.DBInstance
+	tags        map[string][]*rds.Tag
+}
+
+func (f *fakeRDS) DescribeDBInstancesPagesWithContext(ctx aws.Context, input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool, opts ...request.Option) error {
+	fn(&rds.DescribeDBInstancesOutput{DBInstances: f.dbInstances}, true)
+	return nil
+}
+
+func (f *fakeRDS) ListTagsForResourceWithContext(ctx aws.Context, input *rds.ListTagsForResourceInput, opts ...request.Option) (*rds.ListTagsForResourceOutput, error) {
+	tags, ok := f.tags[aws.StringValue(input.ResourceName)]
+	if !ok {
+		return nil, errors.New("AccessDenied: not authorized to perform rds:ListTagsForResource")
+	}
+	return &rds.ListTagsForResourceOutput{TagList: tags}, nil
+}
+
+func testDBInstance(id string) *rds.DBInstance {
+	return &rds.DBInstance{
+		DBInstanceIdentifier: aws.String(id),
+		DBInstanceArn:        aws.String("arn:aws:rds:us-east-1:123456789012:db:" + id),
+		DBInstanceClass:      aws.String("db.t2.micro"),
+		DBInstanceStatus:     aws.String("available"),
+		InstanceCreateTime:   aws.Time(time.Now().Add(-time.Hour)),
+	}
+}
+
+func TestGetDBInstancesKeepsInstancesWithoutTags(t *testing.T) {
+	fake := &fakeRDS{
+		dbInstances: []*rds.DBInstance{testDBInstance("db-1"), testDBInstance("db-2")},
+		tags: map[string][]*rds.Tag{
+			"arn:aws:rds:us-east-1:123456789012:db:db-1": {{Key: aws.String("team"), Value: aws.String("a")}},
+		},
+	}
+	dbInstances, err := GetDBInstances(aws.BackgroundContext(), fake, "us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dbInstances) != 2 {
+		t.Fatalf("expected both DB instances, got %+v", dbInstances)
+	}
+	tags := make(map[string]string)
+	for _, db := range dbInstances {
+		tags[db.ID] = db.Tags
+	}
+	if tags["db-1"] != "team:a " || tags["db-2"] != "" {
+		t.Errorf("expected the tags of db-1 only, got %v", tags)
+	}
+}
+
+func TestFormDBSnapshotsKeepsDBInstancesOfEveryAccount(t *testing.T) {
+	regionInfo := make([]RegionInfo, 0)
+	for _, account := range []string{"111111111111", "222222222222"} {
+		db := DBInstanceInfo{
+			ID:              "staging",
+			Arn:             "arn:aws:rds:us-east-1:" + account + ":db:staging",
+			DBInstanceClass: "db.t2.micro",
+			State:           "available",
+			Region:          "us-east-1",
+		}
+		snapshots := FormDBSnapshots([]DBInstanceInfo{db})
+		for i := range snapshots {
+			snapshots[i].AccountID = account
+		}
+		regionInfo = append(regionInfo, RegionInfo{Provider: ProviderAWS, AccountID: account, RegionName: "us-east-1",
+			Status: RegionStatusOK, BillingSnapshots: snapshots})
+	}
+
+	store := NewFileSnapshotStore(t.TempDir())
+	if err := StoreBillingSnapshots(regionInfo, store, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := QuerySnapshots(store, SnapshotFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := make(map[string]bool)
+	for _, dailyEntry := range entries {
+		for _, timeEntry := range dailyEntry {
+			for _, sample := range timeEntry {
+				for _, b := range sample.Regions["us-east-1"] {
+					accounts[b.AccountID] = true
+				}
+			}
+		}
+	}
+	if len(accounts) != 2 {
+		t.Errorf("expected the DB instances of both accounts, got those of %v", accounts)
+	}
+}
+
+func TestDBCostLeavesOutUnpricedDBInstances(t *testing.T) {
+	dbInstances := []DBInstanceInfo{
+		{ID: "priced", DBInstanceClass: "db.t2.micro", HoursUp: 10, Region: DefaultRegion},
+		{ID: "elsewhere", DBInstanceClass: "db.t2.micro", HoursUp: 10, Region: "eu-west-1"},
+		{ID: "unknown", DBInstanceClass: "db.x9.unknown", HoursUp: 10, Region: DefaultRegion},
+	}
+
+	if cost := CalculateDBCost(dbInstances); cost != 0.017*10 {
+		t.Errorf("expected the priced DB instance to cost %v, got %v", 0.017*10, cost)
+	}
+	summary := CreateDBInstanceClassSummary(dbInstances)
+	if micro := summary["db.t2.micro"]; micro.NumberOfInstances != 2 || micro.NumberUnpriced != 1 || micro.TotalHours != 20 || micro.Cost != 0.017*10 {
+		t.Errorf("expected both db.t2.micro instances counted with one unpriced, got %+v", micro)
+	}
+	if unknown := summary["db.x9.unknown"]; unknown.NumberOfInstances != 1 || unknown.NumberUnpriced != 1 {
+		t.Errorf("expected the DB instance of unknown class counted as unpriced, got %+v", unknown)
+	}
+}
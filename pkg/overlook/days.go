@@ -0,0 +1,143 @@
+package overlook
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts of the names of the days snapshots are stored by
+const (
+	// DayLayout names UTC days, the hours of these days are UTC hours
+	DayLayout = "2006-01-02"
+	// LegacyDayLayout named days in local time, with local hours, before snapshots were kept in UTC
+	LegacyDayLayout = "01-02-2006"
+)
+
+// DayOf returns the name of the UTC day of t
+func DayOf(t time.Time) string {
+	return t.UTC().Format(DayLayout)
+}
+
+// IsLegacyDay reports whether day is named in the legacy local time layout
+func IsLegacyDay(day string) bool {
+	_, err := time.Parse(LegacyDayLayout, day)
+	return err == nil
+}
+
+// parseDay returns midnight of a day named in either layout, in UTC or in local time for legacy days
+func parseDay(day string) (time.Time, error) {
+	if t, err := time.ParseInLocation(DayLayout, day, time.UTC); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(LegacyDayLayout, day, time.Local)
+}
+
+// hourStart returns the start of an hour of a day of the billing data
+func hourStart(date string, hour int) time.Time {
+	day, err := parseDay(date)
+	if err != nil {
+		return time.Time{}
+	}
+	// Built from the wall clock rather than by adding hours, so legacy days with a DST change keep their hours
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, day.Location())
+}
+
+// sampleKey returns the key of a sample taken at t, samples are keyed by the second they were taken at in UTC
+func sampleKey(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// sampleTime returns when a sample of a day was taken from its key. Samples written before they were
+// keyed by time are keyed by hour, they were taken at the start of the hour.
+func sampleTime(date string, key string) time.Time {
+	if t, err := time.Parse(time.RFC3339, key); err == nil {
+		return t
+	}
+	hour, err := strconv.Atoi(key)
+	if err != nil {
+		return time.Time{}
+	}
+	return hourStart(date, hour)
+}
+
+// timedSample is a sample of a day along with when it was taken and the hours it stands for
+type timedSample struct {
+	Key    string
+	Time   time.Time
+	Hours  float64
+	Sample BillingSampleEntry
+}
+
+// timedSamples returns the samples of a day in the order they were taken. Each sample stands for the
+// time until the next sample, no longer than its sampling interval, so the instance-hours of a day are
+// the sum of the hours of the samples an instance was running in. The last sample of a day covers the
+// time past midnight up to its interval, the time before the first sample of a day is the previous
+// day's, so a day sampled every hour at any minute counts 24 hours.
+func timedSamples(date string, dayEntry BillingTimeEntry) []timedSample {
+	samples := make([]timedSample, 0, len(dayEntry))
+	for key, sample := range dayEntry {
+		samples = append(samples, timedSample{Key: key, Time: sampleTime(date, key), Sample: sample})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+
+	for i := range samples {
+		d := samples[i].Sample.SampleInterval()
+		if i+1 < len(samples) {
+			if gap := samples[i+1].Time.Sub(samples[i].Time); gap < d {
+				d = gap
+			}
+		}
+		if d > 0 {
+			samples[i].Hours = d.Hours()
+		}
+	}
+	return samples
+}
+
+// dateOf returns the calendar date of t as midnight UTC
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// dayFileName returns the name of the file of a day relative to the store, days are kept in a
+// directory per year and month when nested is set
+func dayFileName(day string, nested bool) string {
+	if t, err := time.Parse(DayLayout, day); err == nil && nested {
+		return t.Format("2006/01/02") + ".json"
+	}
+	return day + ".json"
+}
+
+// dayFromFileName returns the day of a file name relative to the store, in either layout and
+// flat or nested
+func dayFromFileName(name string) (string, bool) {
+	if !strings.HasSuffix(name, ".json") || strings.HasPrefix(path.Base(name), ".") {
+		return "", false
+	}
+	name = strings.TrimSuffix(name, ".json")
+	if t, err := time.Parse("2006/01/02", name); err == nil {
+		return t.Format(DayLayout), true
+	}
+	if _, err := parseDay(name); err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+// sortDays orders days in either layout most recent first, removing duplicates
+func sortDays(days []string) []string {
+	seen := make(map[string]bool)
+	sorted := make([]string, 0, len(days))
+	for _, day := range days {
+		if !seen[day] {
+			seen[day] = true
+			sorted = append(sorted, day)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return hourStart(sorted[i], 0).After(hourStart(sorted[j], 0)) })
+	return sorted
+}
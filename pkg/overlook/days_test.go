@@ -0,0 +1,158 @@
+package overlook
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// testTimeEntry returns samples taken at times, each with a running instance and the interval
+func testTimeEntry(interval time.Duration, times ...time.Time) BillingTimeEntry {
+	timeEntry := make(BillingTimeEntry)
+	for _, at := range times {
+		timeEntry[sampleKey(at)] = BillingSampleEntry{
+			Regions: BillingRegionEntry{"us-east-1": BillingInstancesEntry{
+				"i-1": {ID: "i-1", InstanceType: "m5.large", Region: "us-east-1", State: InstanceStateRunning},
+			}},
+			Interval: interval,
+		}
+	}
+	return timeEntry
+}
+
+// hourly returns a sample time every hour of 2021-03-01 at minute
+func hourly(minute int) []time.Time {
+	times := make([]time.Time, 0)
+	for hour := 0; hour < 24; hour++ {
+		times = append(times, time.Date(2021, 3, 1, hour, minute, 0, 0, time.UTC))
+	}
+	return times
+}
+
+func sampleHours(samples []timedSample) []string {
+	hours := make([]string, 0)
+	for _, s := range samples {
+		hours = append(hours, fmt.Sprintf("%s=%.2f", s.Time.Format("15:04"), s.Hours))
+	}
+	return hours
+}
+
+func TestTimedSamples(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2021, 3, 1, hour, minute, 0, 0, time.UTC) }
+	tests := []struct {
+		name     string
+		interval time.Duration
+		times    []time.Time
+		expected string
+	}{
+		{"closer than the interval", time.Hour, []time.Time{at(1, 30), at(1, 45), at(2, 30)},
+			"[01:30=0.25 01:45=0.75 02:30=1.00]"},
+		{"shorter interval", 20 * time.Minute, []time.Time{at(3, 0), at(3, 20), at(3, 40)},
+			"[03:00=0.33 03:20=0.33 03:40=0.33]"},
+		// A sample stands for no longer than its interval across a gap
+		{"gap", time.Hour, []time.Time{at(1, 30), at(5, 30)},
+			"[01:30=1.00 05:30=1.00]"},
+		// The last sample of the day covers the time past midnight
+		{"day boundary", time.Hour, []time.Time{at(22, 30), at(23, 30)},
+			"[22:30=1.00 23:30=1.00]"},
+	}
+	for _, tc := range tests {
+		samples := timedSamples("2021-03-01", testTimeEntry(tc.interval, tc.times...))
+		if hours := fmt.Sprint(sampleHours(samples)); hours != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, hours)
+		}
+	}
+}
+
+func TestTimedSamplesLegacyHourKeys(t *testing.T) {
+	timeEntry := make(BillingTimeEntry)
+	for _, key := range []string{"10", "9", "11"} {
+		timeEntry[key] = BillingSampleEntry{Regions: make(BillingRegionEntry)}
+	}
+	samples := timedSamples("2021-03-01", timeEntry)
+	if keys := fmt.Sprint([]string{samples[0].Key, samples[1].Key, samples[2].Key}); keys != "[9 10 11]" {
+		t.Errorf("expected the samples by hour, got %s", keys)
+	}
+	for _, s := range samples {
+		if s.Hours != 1 || s.Time.Minute() != 0 {
+			t.Errorf("expected the legacy sample %s to stand for its hour, got %+v", s.Key, s)
+		}
+	}
+}
+
+func TestGetReportCountsWholeDayOfSamples(t *testing.T) {
+	for _, minute := range []int{0, 30} {
+		report := GetReport(BillingDailyEntry{"2021-03-01": testTimeEntry(time.Hour, hourly(minute)...)})
+		hours := report.Regions["us-east-1"].InstanceTypes["m5.large"].Hours
+		if math.Abs(hours-24) > 1e-9 {
+			t.Errorf("expected an instance sampled every hour at :%02d to run 24 hours, got %.2f", minute, hours)
+		}
+	}
+}
+
+// useLocalZone makes zone the local time zone until the test ends
+func useLocalZone(t *testing.T, zone *time.Location) {
+	previous := time.Local
+	time.Local = zone
+	t.Cleanup(func() { time.Local = previous })
+}
+
+func TestParseDay(t *testing.T) {
+	useLocalZone(t, time.FixedZone("EST", -5*60*60))
+
+	tests := []struct {
+		day      string
+		expected time.Time
+	}{
+		{"2021-03-01", time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+		// Legacy days start at local midnight
+		{"03-01-2021", time.Date(2021, 3, 1, 5, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		day, err := parseDay(tc.day)
+		if err != nil || !day.Equal(tc.expected) {
+			t.Errorf("expected %s to start at %v, got %v %v", tc.day, tc.expected, day, err)
+		}
+	}
+	for _, day := range []string{"2021-13-01", "2021/03/01", "yesterday"} {
+		if _, err := parseDay(day); err == nil {
+			t.Errorf("expected %s not to be a day", day)
+		}
+	}
+}
+
+func TestDayFromFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		day  string
+		ok   bool
+	}{
+		{"2021-03-01.json", "2021-03-01", true},
+		{"2021/03/01.json", "2021-03-01", true},
+		{"03-01-2021.json", "03-01-2021", true},
+		{"2021-03-01", "", false},
+		{"2021/13/01.json", "", false},
+		{"2021/03/.01.json", "", false},
+		{".2021-03-01.json", "", false},
+		{"2021-03-01.json.tmp", "", false},
+		{"notes.json", "", false},
+	}
+	for _, tc := range tests {
+		day, ok := dayFromFileName(tc.name)
+		if day != tc.day || ok != tc.ok {
+			t.Errorf("expected %s to be day %q %v, got %q %v", tc.name, tc.day, tc.ok, day, ok)
+		}
+	}
+}
+
+func TestSortDaysAcrossYears(t *testing.T) {
+	useLocalZone(t, time.FixedZone("EST", -5*60*60))
+
+	days := sortDays([]string{"2020-12-31", "2021-01-01", "2019-06-01", "12-31-2020", "2021-01-01", "01-02-2020"})
+	// The legacy day of 12-31-2020 starts at 05:00 UTC, after the UTC day of 2020-12-31
+	expected := "[2021-01-01 12-31-2020 2020-12-31 01-02-2020 2019-06-01]"
+	if fmt.Sprint(days) != expected {
+		t.Errorf("expected %s, got %v", expected, days)
+	}
+}
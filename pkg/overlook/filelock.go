@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package overlook
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive advisory lock on the file name, creating it if needed, and returns the
+// function releasing it. The lock is released by the kernel if the process dies.
+func lockFile(name string) (func() error, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0660)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() error {
+		defer CheckClose(f)
+		return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	}, nil
+}
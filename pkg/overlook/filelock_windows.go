@@ -0,0 +1,26 @@
+package overlook
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile takes an exclusive lock on the file name, creating it if needed, and returns the function
+// releasing it. The lock is released by Windows if the process dies.
+func lockFile(name string) (func() error, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0660)
+	if err != nil {
+		return nil, err
+	}
+	// Lock the whole file, a lock is held on a range of bytes which may lie past its end
+	ol := new(windows.Overlapped)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, ^uint32(0), ^uint32(0), ol); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() error {
+		defer CheckClose(f)
+		return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, ^uint32(0), ^uint32(0), ol)
+	}, nil
+}
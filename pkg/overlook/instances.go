@@ -2,54 +2,143 @@ package overlook
 
 import (
 	"fmt"
-	"os"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	log "github.com/sirupsen/logrus"
 )
 
 func hoursSince(fromTime time.Time) float64 {
-	return time.Since(fromTime).Hours()
+	return Now().Sub(fromTime).Hours()
 }
 
 // DisplayRegionInfo prints info to stdout
 func DisplayRegionInfo(regionInfo []RegionInfo) {
 	for _, r := range regionInfo {
-		if r.TypeSummary != nil && len(r.TypeSummary) > 0 {
-			fmt.Println(r.RegionName)
+		if r.Status != RegionStatusOK {
+			fmt.Println(r.AccountID, r.RegionName, r.Status, r.Err)
+			log.Errorln(r.AccountID, r.RegionName, r.Status, r.Err)
+		}
+		if len(r.TypeSummary) > 0 || len(r.VolumeTypeSummary) > 0 || len(r.NetworkSummary) > 0 || len(r.DBSummary) > 0 {
+			fmt.Println(r.AccountID, r.RegionName)
 		}
 		for _, sum := range r.TypeSummary {
 			fmt.Println("\t", sum.InstanceType)
-			fmt.Println("\t\t Number of Instances:", sum.NumberOfInstances)
+			fmt.Println("\t\t Number of Instances:", sum.NumberOfInstances, "Not Running:", sum.NumberNotRunning)
 			fmt.Printf("\t\t TotalHours: %.2f\n", sum.TotalHours)
 			fmt.Printf("\t\t Cost of Current Running: %.2f\n", sum.Cost)
+			if sum.NumberUnpriced > 0 {
+				fmt.Println("\t\t Unknown Cost:", sum.NumberUnpriced)
+			}
 
 			log.Infof("%s: %s: Number of Instances: %d, Total Hours: %.2f, Cost of Current Running: %.2f", r.RegionName, sum.InstanceType, sum.NumberOfInstances, sum.TotalHours, sum.Cost)
 		}
+		for _, sum := range r.VolumeTypeSummary {
+			fmt.Println("\t EBS", sum.VolumeType)
+			fmt.Println("\t\t Number of Volumes:", sum.NumberOfVolumes, "Unattached:", sum.NumberUnattached)
+			fmt.Println("\t\t Total GB:", sum.TotalGB)
+			fmt.Printf("\t\t Cost Per Hour: %.4f, Unattached Cost Per Hour: %.4f\n", sum.CostPerHour, sum.UnattachedCostPerHour)
+			if sum.NumberUnpriced > 0 {
+				fmt.Println("\t\t Unknown Cost:", sum.NumberUnpriced)
+			}
+
+			log.Infof("%s: EBS %s: Number of Volumes: %d, Unattached: %d, Total GB: %d, Cost Per Hour: %.4f, Unattached Cost Per Hour: %.4f", r.RegionName, sum.VolumeType, sum.NumberOfVolumes, sum.NumberUnattached, sum.TotalGB, sum.CostPerHour, sum.UnattachedCostPerHour)
+		}
+		for _, sum := range r.NetworkSummary {
+			fmt.Println("\t", sum.Category)
+			fmt.Println("\t\t Number:", sum.Number, "Idle:", sum.NumberIdle)
+			fmt.Printf("\t\t Cost Per Hour: %.4f, Idle Cost Per Hour: %.4f\n", sum.CostPerHour, sum.IdleCostPerHour)
+			if sum.NumberUnpriced > 0 {
+				fmt.Println("\t\t Unknown Cost:", sum.NumberUnpriced)
+			}
+
+			log.Infof("%s: %s: Number: %d, Idle: %d, Cost Per Hour: %.4f, Idle Cost Per Hour: %.4f", r.RegionName, sum.Category, sum.Number, sum.NumberIdle, sum.CostPerHour, sum.IdleCostPerHour)
+		}
+		for _, sum := range r.DBSummary {
+			fmt.Println("\t RDS", sum.DBInstanceClass)
+			fmt.Println("\t\t Number of Instances:", sum.NumberOfInstances)
+			fmt.Printf("\t\t TotalHours: %.2f\n", sum.TotalHours)
+			fmt.Printf("\t\t Cost of Current Running: %.2f\n", sum.Cost)
+			if sum.NumberUnpriced > 0 {
+				fmt.Println("\t\t Unknown Cost:", sum.NumberUnpriced)
+			}
+
+			log.Infof("%s: RDS %s: Number of Instances: %d, Total Hours: %.2f, Cost of Current Running: %.2f", r.RegionName, sum.DBInstanceClass, sum.NumberOfInstances, sum.TotalHours, sum.Cost)
+		}
+	}
+}
+
+// UnpricedInstanceTypes returns the price keys of the instances, and the types of the other resources,
+// sampled in regionInfo that no price is known for
+func UnpricedInstanceTypes(regionInfo []RegionInfo) []string {
+	unpriced := make(map[string]bool)
+	for _, r := range regionInfo {
+		for _, b := range r.BillingSnapshots {
+			if b.Unpriced {
+				unpriced[b.unpricedName()] = true
+			}
+		}
+	}
+	return sortedKeys(unpriced)
+}
+
+// WarnUnpriced prints a warning listing the instance and resource types no price is known for
+func WarnUnpriced(priceKeys []string) {
+	if len(priceKeys) == 0 {
+		return
+	}
+	fmt.Println("WARNING: No price is known for these types, their cost is unknown:")
+	for _, k := range priceKeys {
+		fmt.Println("\t", k)
 	}
+	log.Warnln("No price is known for types:", strings.Join(priceKeys, ", "))
 }
 
-// CalculateCost calculates cost of current instances
-func CalculateCost(instances []InstanceInfo) (float64, error) {
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CalculateCost calculates cost of current instances, instances no price is known for are left out
+func CalculateCost(instances []InstanceInfo) float64 {
 	runningTotal := 0.0
 	for _, inst := range instances {
 		rawEstimatedCost, err := CalculateCostPer(inst)
 		if err != nil {
-			return 0, err
+			continue
 		}
 		inst.Cost = rawEstimatedCost
 		runningTotal += rawEstimatedCost
 	}
-	return runningTotal, nil
+	return runningTotal
+}
+
+// instanceCostPerHour returns the rate an instance is billed at now, spot instances are billed the spot
+// price of their availability zone and fall back to the on-demand rate when it is not known. The source
+// of the rate is returned along with it.
+func instanceCostPerHour(inst InstanceInfo) (float64, string, error) {
+	if inst.Lifecycle == LifecycleSpot {
+		cost, err := GetSpotCostPerHour(inst.PriceKey(), inst.AvailabilityZone, Now())
+		if err == nil {
+			return cost, PriceSourceSpot, nil
+		}
+		log.Warnln("Pricing spot instance", inst.ID, "at the on-demand rate:", err)
+	}
+	return GetInstanceCostPerHour(inst.Provider, inst.PriceKey(), inst.AvailabilityZone)
 }
 
 // CalculateCostPer cost of a single instance
 func CalculateCostPer(inst InstanceInfo) (float64, error) {
-	cost, err := GetCostPerHour(inst.InstanceType)
+	cost, _, err := instanceCostPerHour(inst)
 	if err != nil {
-		log.Errorln(err)
 		return 0, err
 	}
 	rawEstimatedCost := cost * inst.HoursUp
@@ -62,11 +151,18 @@ func CreateInstanceTypeSummary(instances []InstanceInfo) map[string]InstanceType
 	for _, inst := range instances {
 		instSumm := summary[inst.InstanceType]
 		instSumm.InstanceType = inst.InstanceType
+		if inst.State != InstanceStateRunning {
+			instSumm.NumberNotRunning++
+			summary[inst.InstanceType] = instSumm
+			continue
+		}
 		instSumm.NumberOfInstances++
 		instSumm.TotalHours += inst.HoursUp
 		x, err := CalculateCostPer(inst)
 		if err != nil {
-			log.Errorln("Skipping Instance ID: " + *inst.Instance.InstanceId + ", of type: " + inst.InstanceType)
+			// Counted without a cost so the instance still shows up in the summary
+			instSumm.NumberUnpriced++
+			summary[inst.InstanceType] = instSumm
 			continue
 		}
 		instSumm.Cost += x
@@ -80,22 +176,24 @@ func FormBillingSnapshots(instances []InstanceInfo) []BillingSnapshot {
 	var billSnaps = make([]BillingSnapshot, 0)
 	for _, inst := range instances {
 		b := BillingSnapshot{}
-		cost, err := GetCostPerHour(inst.InstanceType)
+		b.ID = inst.ID
+		b.ResourceType = ResourceTypeInstance
+		b.Provider = inst.Provider
+		cost, source, err := instanceCostPerHour(inst)
 		if err != nil {
-			log.Infoln(err)
-			os.Exit(1)
+			b.Unpriced = true
 		}
-		b.ID = *inst.Instance.InstanceId
+		b.PriceListVersion = sourcePriceListVersion(source)
 		b.CostPerHour = cost
-
-		var x float64
-		x, err = CalculateCostPer(inst)
-		if err != nil {
-			log.Errorln("Skipping Instance ID: " + *inst.Instance.InstanceId + ", of type: " + inst.InstanceType)
-			continue
-		}
-		b.CurrentCost = x
+		b.CurrentCost = cost * inst.HoursUp
 		b.InstanceType = inst.InstanceType
+		b.Platform = inst.PlatformDetails
+		if b.Platform == "" && inst.Platform != "" {
+			b.Platform = PricingOperatingSystem(inst.Platform, "", "")
+		}
+		b.UsageOperation = inst.UsageOperation
+		b.Tenancy = inst.Tenancy
+		b.Lifecycle = inst.Lifecycle
 		b.HoursUp = inst.HoursUp
 		b.Tags = inst.Tags
 		b.State = inst.State
@@ -108,42 +206,53 @@ func FormBillingSnapshots(instances []InstanceInfo) []BillingSnapshot {
 }
 
 //
-// GetInstances a list of instances in the region ordered by launchTime
+// GetInstances a list of instances in the region, in every state, ordered by launchTime
 //
-func GetInstances(svc *ec2.EC2) ([]InstanceInfo, error) {
+func GetInstances(ctx aws.Context, svc ec2iface.EC2API, region string) ([]InstanceInfo, error) {
 	instances := make([]InstanceInfo, 0)
-	resultInstances, err := svc.DescribeInstances(nil)
+	err := svc.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{},
+		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, r := range page.Reservations {
+				for _, inst := range r.Instances {
+					var info InstanceInfo
+					var tags string
+					for _, t := range inst.Tags {
+						tags += fmt.Sprintf("%s:%s ", *t.Key, *t.Value)
+					}
+					info.ID = *inst.InstanceId
+					info.Provider = ProviderAWS
+					// LaunchTime is when the instance was last started, only running instances accrue hours
+					if *inst.State.Name == ec2.InstanceStateNameRunning {
+						info.HoursUp = hoursSince(*inst.LaunchTime)
+					}
+					info.AvailabilityZone = *inst.Placement.AvailabilityZone
+					info.Region = region
+					// EC2 states are the shared instance states
+					info.State = *inst.State.Name
+					info.Tags = tags
+					info.InstanceType = *inst.InstanceType
+					info.Platform = aws.StringValue(inst.Platform)
+					info.PlatformDetails = aws.StringValue(inst.PlatformDetails)
+					info.UsageOperation = aws.StringValue(inst.UsageOperation)
+					info.Tenancy = aws.StringValue(inst.Placement.Tenancy)
+					info.Lifecycle = LifecycleOnDemand
+					if inst.InstanceLifecycle != nil {
+						info.Lifecycle = *inst.InstanceLifecycle
+					}
+					if inst.IamInstanceProfile != nil {
+						if inst.IamInstanceProfile.Arn != nil {
+							info.Arn = *inst.IamInstanceProfile.Arn
+						}
+					}
+					instances = append(instances, info)
+				}
+			}
+			return true
+		})
 	if err != nil {
 		log.Infoln("Error", err)
 		return nil, err
 	}
-
-	for _, r := range resultInstances.Reservations {
-		for _, inst := range r.Instances {
-			if *inst.State.Name != "running" {
-				//log.Infoln("Skipping since state is: ", *inst.State.Name)
-				continue
-			}
-			var info InstanceInfo
-			var tags string
-			for _, t := range inst.Tags {
-				tags += fmt.Sprintf("%s:%s ", *t.Key, *t.Value)
-			}
-			info.Instance = inst
-			info.HoursUp = hoursSince(*inst.LaunchTime)
-			info.AvailabilityZone = *inst.Placement.AvailabilityZone
-			info.Region = *svc.Config.Region
-			info.State = *inst.State.Name
-			info.Tags = tags
-			info.InstanceType = *inst.InstanceType
-			if inst.IamInstanceProfile != nil {
-				if inst.IamInstanceProfile.Arn != nil {
-					info.Arn = *inst.IamInstanceProfile.Arn
-				}
-			}
-			instances = append(instances, info)
-		}
-	}
 	//
 	// Sort by HoursUp
 	//
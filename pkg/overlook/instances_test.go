@@ -0,0 +1,117 @@
+package overlook
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// fakeInstancesEC2 answers DescribeInstances with pages linked by NextToken, keyed by the token requesting them
+type fakeInstancesEC2 struct {
+	ec2iface.EC2API
+	pages    map[string]*ec2.DescribeInstancesOutput
+	requests []string
+}
+
+func (f *fakeInstancesEC2) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	token := aws.StringValue(input.NextToken)
+	f.requests = append(f.requests, token)
+	page, ok := f.pages[token]
+	if !ok {
+		return nil, fmt.Errorf("unknown NextToken: %s", token)
+	}
+	return page, nil
+}
+
+// DescribeInstancesPagesWithContext follows NextToken the way the SDK paginator does
+func (f *fakeInstancesEC2) DescribeInstancesPagesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool, opts ...request.Option) error {
+	in := *input
+	for {
+		page, err := f.DescribeInstancesWithContext(ctx, &in, opts...)
+		if err != nil {
+			return err
+		}
+		lastPage := aws.StringValue(page.NextToken) == ""
+		if !fn(page, lastPage) || lastPage {
+			return nil
+		}
+		in.NextToken = page.NextToken
+	}
+}
+
+func testInstance(id string, state string, launched time.Time) *ec2.Instance {
+	return &ec2.Instance{
+		InstanceId:   aws.String(id),
+		InstanceType: aws.String("m5.large"),
+		LaunchTime:   aws.Time(launched),
+		Placement:    &ec2.Placement{AvailabilityZone: aws.String("us-east-1a")},
+		State:        &ec2.InstanceState{Name: aws.String(state)},
+	}
+}
+
+func TestGetInstancesCollectsEveryPage(t *testing.T) {
+	launched := time.Now().Add(-2 * time.Hour)
+	fake := &fakeInstancesEC2{pages: map[string]*ec2.DescribeInstancesOutput{
+		"": {
+			Reservations: []*ec2.Reservation{
+				{Instances: []*ec2.Instance{testInstance("i-1", ec2.InstanceStateNameRunning, launched)}},
+				{Instances: []*ec2.Instance{testInstance("i-2", ec2.InstanceStateNameStopped, launched)}},
+			},
+			NextToken: aws.String("page-2"),
+		},
+		// Pages may be empty and still have a next page
+		"page-2": {NextToken: aws.String("page-3")},
+		"page-3": {
+			Reservations: []*ec2.Reservation{
+				{Instances: []*ec2.Instance{
+					testInstance("i-3", ec2.InstanceStateNameRunning, launched),
+					testInstance("i-4", ec2.InstanceStateNamePending, launched),
+				}},
+			},
+		},
+	}}
+
+	instances, err := GetInstances(aws.BackgroundContext(), fake, "us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(fake.requests) != fmt.Sprint([]string{"", "page-2", "page-3"}) {
+		t.Errorf("expected every page to be requested once, got %q", fake.requests)
+	}
+
+	ids := make([]string, 0)
+	for _, inst := range instances {
+		ids = append(ids, inst.ID)
+		if inst.Region != "us-east-1" || inst.Provider != ProviderAWS || inst.Lifecycle != LifecycleOnDemand {
+			t.Errorf("unexpected instance %+v", inst)
+		}
+		running := inst.State == ec2.InstanceStateNameRunning
+		if running != (inst.HoursUp > 0) {
+			t.Errorf("instance %s in state %s is up %.2f hours", inst.ID, inst.State, inst.HoursUp)
+		}
+	}
+	sort.Strings(ids)
+	if fmt.Sprint(ids) != fmt.Sprint([]string{"i-1", "i-2", "i-3", "i-4"}) {
+		t.Errorf("expected every instance of every page, got %v", ids)
+	}
+}
+
+func TestGetInstancesFailsOnPageError(t *testing.T) {
+	fake := &fakeInstancesEC2{pages: map[string]*ec2.DescribeInstancesOutput{
+		"": {
+			Reservations: []*ec2.Reservation{
+				{Instances: []*ec2.Instance{testInstance("i-1", ec2.InstanceStateNameRunning, time.Now())}},
+			},
+			NextToken: aws.String("missing"),
+		},
+	}}
+	if _, err := GetInstances(aws.BackgroundContext(), fake, "us-east-1"); err == nil {
+		t.Fatal("expected the error of the second page, a partial list of instances was returned")
+	}
+}
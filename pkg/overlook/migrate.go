@@ -0,0 +1,79 @@
+package overlook
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// MigrateSnapshots moves the days of store named in the legacy local time layout to UTC days with samples
+// keyed by UTC time, returning the number of legacy days migrated. Every sample keeps the time it was taken
+// at, so a local day is spread over the two UTC days it overlaps and merged with any samples those days
+// already have. The legacy days are only removed once all of the UTC days are written.
+func MigrateSnapshots(store SnapshotStore) (int, error) {
+	unlock, err := lockStore(store)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	days, err := store.Days()
+	if err != nil {
+		return 0, err
+	}
+	legacyDays := make([]string, 0)
+	migrated := make(map[string]BillingTimeEntry)
+	for _, day := range days {
+		if !IsLegacyDay(day) {
+			continue
+		}
+		dailyEntry, err := store.Read(day)
+		if err != nil {
+			return 0, err
+		}
+		for key, sample := range dailyEntry[day] {
+			t := sampleTime(day, key)
+			ymd := DayOf(t)
+			timeEntry, ok := migrated[ymd]
+			if !ok {
+				timeEntry = make(BillingTimeEntry)
+				migrated[ymd] = timeEntry
+			}
+			timeEntry[sampleKey(t)] = sample
+		}
+		legacyDays = append(legacyDays, day)
+	}
+
+	for ymd, timeEntry := range migrated {
+		dailyEntry, err := store.Read(ymd)
+		if err != nil {
+			return 0, err
+		}
+		existing, ok := dailyEntry[ymd]
+		if !ok {
+			existing = make(BillingTimeEntry)
+		}
+		taken := make(map[int64]bool)
+		for key := range existing {
+			taken[sampleTime(ymd, key).Unix()] = true
+		}
+		for key, sample := range timeEntry {
+			if taken[sampleTime(ymd, key).Unix()] {
+				// Samples taken since the switch to UTC are newer than the legacy sample of the same time
+				log.Warnln("Keeping the existing sample of", key, "over the legacy sample")
+				continue
+			}
+			existing[key] = sample
+		}
+		dailyEntry[ymd] = existing
+		if err := store.Write(ymd, dailyEntry); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, day := range legacyDays {
+		if err := store.Delete(day); err != nil {
+			return 0, err
+		}
+		log.Infoln("Migrated snapshots of", day)
+	}
+	return len(legacyDays), nil
+}
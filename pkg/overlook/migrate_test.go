@@ -0,0 +1,93 @@
+package overlook
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testLegacyDailyEntry returns a legacy day with a sample keyed by each local hour
+func testLegacyDailyEntry(day string, id string, hours ...int) BillingDailyEntry {
+	timeEntry := make(BillingTimeEntry)
+	for _, hour := range hours {
+		timeEntry[strconv.Itoa(hour)] = BillingSampleEntry{
+			Regions:  BillingRegionEntry{"us-east-1": BillingInstancesEntry{id: {ID: id, InstanceType: "m5.large", Region: "us-east-1"}}},
+			Interval: time.Hour,
+		}
+	}
+	return BillingDailyEntry{day: timeEntry}
+}
+
+// storedSamples lists the samples of a day of store by their key along with the instance in them
+func storedSamples(t *testing.T, store SnapshotStore, day string) []string {
+	t.Helper()
+	dailyEntry, err := store.Read(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]string, 0)
+	for key, sample := range dailyEntry[day] {
+		for id := range sample.Regions["us-east-1"] {
+			samples = append(samples, key+"="+id)
+		}
+	}
+	sort.Strings(samples)
+	return samples
+}
+
+func TestMigrateSnapshots(t *testing.T) {
+	useLocalZone(t, time.FixedZone("EST", -5*60*60))
+
+	for _, nested := range []bool{false, true} {
+		dir := t.TempDir()
+		store := &FileSnapshotStore{Dir: dir, Nested: nested}
+		// Local hours 0, 20 and 23 of March 1st are 05:00 that day and 01:00 and 04:00 the next day in UTC
+		if err := store.Write("03-01-2021", testLegacyDailyEntry("03-01-2021", "legacy", 0, 20, 23)); err != nil {
+			t.Fatal(err)
+		}
+		existing := BillingDailyEntry{"2021-03-02": testTimeEntry(time.Hour,
+			time.Date(2021, 3, 2, 1, 0, 0, 0, time.UTC), time.Date(2021, 3, 2, 10, 0, 0, 0, time.UTC))}
+		if err := store.Write("2021-03-02", existing); err != nil {
+			t.Fatal(err)
+		}
+
+		migrated, err := MigrateSnapshots(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migrated != 1 {
+			t.Errorf("nested %v: expected one legacy day migrated, got %d", nested, migrated)
+		}
+		days, err := store.Days()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(days) != "[2021-03-02 2021-03-01]" {
+			t.Errorf("nested %v: expected only UTC days left, got %v", nested, days)
+		}
+		expected := map[string]string{
+			"2021-03-01": "[2021-03-01T05:00:00Z=legacy]",
+			// The sample taken since the switch to UTC is kept over the legacy sample of the same time
+			"2021-03-02": "[2021-03-02T01:00:00Z=i-1 2021-03-02T04:00:00Z=legacy 2021-03-02T10:00:00Z=i-1]",
+		}
+		for day, samples := range expected {
+			if stored := fmt.Sprint(storedSamples(t, store, day)); stored != samples {
+				t.Errorf("nested %v: expected %s to have %s, got %s", nested, day, samples, stored)
+			}
+			if !Exists(filepath.Join(dir, filepath.FromSlash(dayFileName(day, nested)))) {
+				t.Errorf("nested %v: expected %s in the layout of the store", nested, day)
+			}
+			if Exists(filepath.Join(dir, filepath.FromSlash(dayFileName(day, !nested)))) {
+				t.Errorf("nested %v: expected %s not to be written in the other layout", nested, day)
+			}
+		}
+
+		// Nothing is left to migrate
+		if migrated, err := MigrateSnapshots(store); err != nil || migrated != 0 {
+			t.Errorf("nested %v: expected nothing to migrate again, got %d %v", nested, migrated, err)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package overlook
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	log "github.com/sirupsen/logrus"
+)
+
+// Load balancer types, classic load balancers come from the ELB API and the rest from ELBv2
+const (
+	LoadBalancerTypeClassic     = "classic"
+	LoadBalancerTypeApplication = "application"
+	LoadBalancerTypeNetwork     = "network"
+	LoadBalancerTypeGateway     = "gateway"
+)
+
+// maxTagsPerCall is the number of load balancers DescribeTags accepts at once
+const maxTagsPerCall = 20
+
+// GetAddresses returns the Elastic IPs allocated in the region
+func GetAddresses(ctx aws.Context, svc ec2iface.EC2API, region string) ([]NetworkResourceInfo, error) {
+	resources := make([]NetworkResourceInfo, 0)
+	result, err := svc.DescribeAddressesWithContext(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		log.Infoln("Error", err)
+		return nil, err
+	}
+	for _, addr := range result.Addresses {
+		var info NetworkResourceInfo
+		var tags string
+		for _, t := range addr.Tags {
+			tags += fmt.Sprintf("%s:%s ", *t.Key, *t.Value)
+		}
+		info.ID = aws.StringValue(addr.AllocationId)
+		if info.ID == "" {
+			// EC2-Classic addresses have no allocation ID
+			info.ID = aws.StringValue(addr.PublicIp)
+		}
+		info.ResourceType = ResourceTypeElasticIP
+		info.Region = region
+		info.Tags = tags
+		// An Elastic IP that is not associated is billed while doing nothing
+		info.Idle = addr.AssociationId == nil
+		if info.Idle {
+			info.State = "unassociated"
+		} else {
+			info.State = "associated"
+		}
+		resources = append(resources, info)
+	}
+	return resources, nil
+}
+
+// GetNatGateways returns the NAT gateways in the region which are not deleted
+func GetNatGateways(ctx aws.Context, svc ec2iface.EC2API, region string) ([]NetworkResourceInfo, error) {
+	resources := make([]NetworkResourceInfo, 0)
+	input := &ec2.DescribeNatGatewaysInput{}
+	for {
+		result, err := svc.DescribeNatGatewaysWithContext(ctx, input)
+		if err != nil {
+			log.Infoln("Error", err)
+			return nil, err
+		}
+		for _, gw := range result.NatGateways {
+			state := aws.StringValue(gw.State)
+			if state != ec2.NatGatewayStateAvailable && state != ec2.NatGatewayStatePending {
+				continue
+			}
+			var info NetworkResourceInfo
+			var tags string
+			for _, t := range gw.Tags {
+				tags += fmt.Sprintf("%s:%s ", *t.Key, *t.Value)
+			}
+			info.ID = *gw.NatGatewayId
+			info.ResourceType = ResourceTypeNatGateway
+			info.Region = region
+			info.State = state
+			info.Tags = tags
+			if gw.CreateTime != nil {
+				info.HoursUp = hoursSince(*gw.CreateTime)
+			}
+			resources = append(resources, info)
+		}
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+	return resources, nil
+}
+
+// classicLoadBalancerArn returns the ARN of a classic load balancer, which is not part of its description.
+// Unlike their name, the ARN of classic load balancers is unique across accounts.
+func classicLoadBalancerArn(region string, accountID string, name string) string {
+	return fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:loadbalancer/%s", region, accountID, name)
+}
+
+// GetLoadBalancers returns the classic, application, network and gateway load balancers of an account in the region
+func GetLoadBalancers(ctx aws.Context, elbSvc elbiface.ELBAPI, elbv2Svc elbv2iface.ELBV2API, region string, accountID string) ([]NetworkResourceInfo, error) {
+	resources := make([]NetworkResourceInfo, 0)
+
+	classic := make([]NetworkResourceInfo, 0)
+	classicNames := make([]string, 0)
+	err := elbSvc.DescribeLoadBalancersPagesWithContext(ctx, &elb.DescribeLoadBalancersInput{},
+		func(page *elb.DescribeLoadBalancersOutput, lastPage bool) bool {
+			for _, lb := range page.LoadBalancerDescriptions {
+				var info NetworkResourceInfo
+				info.ID = classicLoadBalancerArn(region, accountID, *lb.LoadBalancerName)
+				info.ResourceType = ResourceTypeLoadBalancer
+				info.LoadBalancerType = LoadBalancerTypeClassic
+				info.Region = region
+				info.State = "active"
+				// A classic load balancer without instances is not serving anything
+				info.Idle = len(lb.Instances) == 0
+				if lb.CreatedTime != nil {
+					info.HoursUp = hoursSince(*lb.CreatedTime)
+				}
+				classic = append(classic, info)
+				classicNames = append(classicNames, *lb.LoadBalancerName)
+			}
+			return true
+		})
+	if err != nil {
+		log.Infoln("Error", err)
+		return nil, err
+	}
+	for start := 0; start < len(classic); start += maxTagsPerCall {
+		end := start + maxTagsPerCall
+		if end > len(classic) {
+			end = len(classic)
+		}
+		names := make([]*string, 0)
+		for _, name := range classicNames[start:end] {
+			names = append(names, aws.String(name))
+		}
+		result, err := elbSvc.DescribeTagsWithContext(ctx, &elb.DescribeTagsInput{LoadBalancerNames: names})
+		if err != nil {
+			log.Infoln("Error", err)
+			return nil, err
+		}
+		tagsByName := make(map[string]string)
+		for _, d := range result.TagDescriptions {
+			var tags string
+			for _, t := range d.Tags {
+				tags += fmt.Sprintf("%s:%s ", *t.Key, *t.Value)
+			}
+			tagsByName[*d.LoadBalancerName] = tags
+		}
+		for i := start; i < end; i++ {
+			classic[i].Tags = tagsByName[classicNames[i]]
+		}
+	}
+	resources = append(resources, classic...)
+
+	modern := make([]NetworkResourceInfo, 0)
+	err = elbv2Svc.DescribeLoadBalancersPagesWithContext(ctx, &elbv2.DescribeLoadBalancersInput{},
+		func(page *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
+			for _, lb := range page.LoadBalancers {
+				var info NetworkResourceInfo
+				info.ID = *lb.LoadBalancerArn
+				info.ResourceType = ResourceTypeLoadBalancer
+				info.LoadBalancerType = aws.StringValue(lb.Type)
+				info.Region = region
+				if lb.State != nil {
+					info.State = aws.StringValue(lb.State.Code)
+				}
+				if lb.CreatedTime != nil {
+					info.HoursUp = hoursSince(*lb.CreatedTime)
+				}
+				modern = append(modern, info)
+			}
+			return true
+		})
+	if err != nil {
+		log.Infoln("Error", err)
+		return nil, err
+	}
+	for start := 0; start < len(modern); start += maxTagsPerCall {
+		end := start + maxTagsPerCall
+		if end > len(modern) {
+			end = len(modern)
+		}
+		arns := make([]*string, 0)
+		for _, info := range modern[start:end] {
+			arns = append(arns, aws.String(info.ID))
+		}
+		result, err := elbv2Svc.DescribeTagsWithContext(ctx, &elbv2.DescribeTagsInput{ResourceArns: arns})
+		if err != nil {
+			log.Infoln("Error", err)
+			return nil, err
+		}
+		tagsByArn := make(map[string]string)
+		for _, d := range result.TagDescriptions {
+			var tags string
+			for _, t := range d.Tags {
+				tags += fmt.Sprintf("%s:%s ", *t.Key, *t.Value)
+			}
+			tagsByArn[*d.ResourceArn] = tags
+		}
+		for i := start; i < end; i++ {
+			modern[i].Tags = tagsByArn[modern[i].ID]
+		}
+	}
+	for i, info := range modern {
+		idle, err := loadBalancerIdle(ctx, elbv2Svc, info.ID)
+		if err != nil {
+			// Without its targets the load balancer is assumed to be serving
+			log.Warnln("Unable to list targets of load balancer", info.ID, "in region", region, err)
+			continue
+		}
+		modern[i].Idle = idle
+	}
+	resources = append(resources, modern...)
+
+	//
+	// Sort by HoursUp
+	//
+	sort.Slice(resources, func(i, j int) bool { return resources[i].HoursUp > resources[j].HoursUp })
+	return resources, nil
+}
+
+// loadBalancerIdle reports whether an application, network or gateway load balancer has no healthy
+// target in any of its target groups, targets of groups without health checks count as healthy
+func loadBalancerIdle(ctx aws.Context, svc elbv2iface.ELBV2API, arn string) (bool, error) {
+	targetGroups := make([]string, 0)
+	err := svc.DescribeTargetGroupsPagesWithContext(ctx, &elbv2.DescribeTargetGroupsInput{LoadBalancerArn: aws.String(arn)},
+		func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+			for _, tg := range page.TargetGroups {
+				targetGroups = append(targetGroups, aws.StringValue(tg.TargetGroupArn))
+			}
+			return true
+		})
+	if err != nil {
+		return false, err
+	}
+	for _, tg := range targetGroups {
+		result, err := svc.DescribeTargetHealthWithContext(ctx, &elbv2.DescribeTargetHealthInput{TargetGroupArn: aws.String(tg)})
+		if err != nil {
+			return false, err
+		}
+		for _, d := range result.TargetHealthDescriptions {
+			if d.TargetHealth == nil {
+				continue
+			}
+			if aws.StringValue(d.TargetHealth.State) == elbv2.TargetHealthStateEnumHealthy ||
+				aws.StringValue(d.TargetHealth.Reason) == elbv2.TargetHealthReasonEnumTargetHealthCheckDisabled {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// CalculateNetworkCost calculates cost of current networking resources since they were created,
+// resources no price is known for are left out
+func CalculateNetworkCost(resources []NetworkResourceInfo) float64 {
+	runningTotal := 0.0
+	for _, res := range resources {
+		cost, err := GetNetworkCostPerHour(res.Region, res.ResourceType, res.LoadBalancerType)
+		if err != nil {
+			continue
+		}
+		runningTotal += cost * res.HoursUp
+	}
+	return runningTotal
+}
+
+// CreateNetworkSummary creates summary info on networking resources by category
+func CreateNetworkSummary(resources []NetworkResourceInfo) map[string]NetworkTypeSummary {
+	var summary = make(map[string]NetworkTypeSummary)
+	for _, res := range resources {
+		category := res.Category()
+		netSumm := summary[category]
+		netSumm.Category = category
+		netSumm.Number++
+		if res.Idle {
+			netSumm.NumberIdle++
+		}
+		costPerHour, err := GetNetworkCostPerHour(res.Region, res.ResourceType, res.LoadBalancerType)
+		if err != nil {
+			netSumm.NumberUnpriced++
+			summary[category] = netSumm
+			continue
+		}
+		netSumm.CostPerHour += costPerHour
+		if res.Idle {
+			netSumm.IdleCostPerHour += costPerHour
+		}
+		summary[category] = netSumm
+	}
+	return summary
+}
+
+// FormNetworkSnapshots forms billing snapshots for networking resources so they are stored next to instances
+func FormNetworkSnapshots(resources []NetworkResourceInfo) []BillingSnapshot {
+	var billSnaps = make([]BillingSnapshot, 0)
+	for _, res := range resources {
+		b := BillingSnapshot{}
+		costPerHour, err := GetNetworkCostPerHour(res.Region, res.ResourceType, res.LoadBalancerType)
+		if err != nil {
+			b.Unpriced = true
+		}
+		b.ID = res.ID
+		b.ResourceType = res.ResourceType
+		b.LoadBalancerType = res.LoadBalancerType
+		b.Idle = res.Idle
+		b.CostPerHour = costPerHour
+		b.CurrentCost = costPerHour * res.HoursUp
+		b.HoursUp = res.HoursUp
+		b.Tags = res.Tags
+		b.State = res.State
+		b.Region = res.Region
+		billSnaps = append(billSnaps, b)
+	}
+	return billSnaps
+}
@@ -0,0 +1,188 @@
+package overlook
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+// fakeELB keeps the tags of its classic load balancers by name
+type fakeELB struct {
+	elbiface.ELBAPI
+	tags map[string]string
+}
+
+func (f *fakeELB) DescribeLoadBalancersPagesWithContext(ctx aws.Context, input *elb.DescribeLoadBalancersInput, fn func(*elb.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	page := &elb.DescribeLoadBalancersOutput{}
+	for name := range f.tags {
+		page.LoadBalancerDescriptions = append(page.LoadBalancerDescriptions, &elb.LoadBalancerDescription{LoadBalancerName: aws.String(name)})
+	}
+	fn(page, true)
+	return nil
+}
+
+func (f *fakeELB) DescribeTagsWithContext(ctx aws.Context, input *elb.DescribeTagsInput, opts ...request.Option) (*elb.DescribeTagsOutput, error) {
+	result := &elb.DescribeTagsOutput{}
+	for _, name := range input.LoadBalancerNames {
+		tag, ok := f.tags[aws.StringValue(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown load balancer: %s", aws.StringValue(name))
+		}
+		result.TagDescriptions = append(result.TagDescriptions, &elb.TagDescription{
+			LoadBalancerName: name,
+			Tags:             []*elb.Tag{{Key: aws.String("team"), Value: aws.String(tag)}},
+		})
+	}
+	return result, nil
+}
+
+// fakeELBV2 keeps the target groups of each load balancer and the health of the targets of each group
+type fakeELBV2 struct {
+	elbv2iface.ELBV2API
+	targetGroups map[string][]string
+	targets      map[string][]*elbv2.TargetHealth
+}
+
+func (f *fakeELBV2) DescribeLoadBalancersPagesWithContext(ctx aws.Context, input *elbv2.DescribeLoadBalancersInput, fn func(*elbv2.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	page := &elbv2.DescribeLoadBalancersOutput{}
+	for arn := range f.targetGroups {
+		page.LoadBalancers = append(page.LoadBalancers, &elbv2.LoadBalancer{
+			LoadBalancerArn: aws.String(arn),
+			Type:            aws.String(elbv2.LoadBalancerTypeEnumApplication),
+			State:           &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumActive)},
+		})
+	}
+	fn(page, true)
+	return nil
+}
+
+func (f *fakeELBV2) DescribeTagsWithContext(ctx aws.Context, input *elbv2.DescribeTagsInput, opts ...request.Option) (*elbv2.DescribeTagsOutput, error) {
+	return &elbv2.DescribeTagsOutput{}, nil
+}
+
+func (f *fakeELBV2) DescribeTargetGroupsPagesWithContext(ctx aws.Context, input *elbv2.DescribeTargetGroupsInput, fn func(*elbv2.DescribeTargetGroupsOutput, bool) bool, opts ...request.Option) error {
+	groups, ok := f.targetGroups[aws.StringValue(input.LoadBalancerArn)]
+	if !ok {
+		return fmt.Errorf("unknown load balancer: %s", aws.StringValue(input.LoadBalancerArn))
+	}
+	// A page per target group
+	for i, tg := range groups {
+		page := &elbv2.DescribeTargetGroupsOutput{TargetGroups: []*elbv2.TargetGroup{{TargetGroupArn: aws.String(tg)}}}
+		if !fn(page, i == len(groups)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeELBV2) DescribeTargetHealthWithContext(ctx aws.Context, input *elbv2.DescribeTargetHealthInput, opts ...request.Option) (*elbv2.DescribeTargetHealthOutput, error) {
+	targets, ok := f.targets[aws.StringValue(input.TargetGroupArn)]
+	if !ok {
+		return nil, fmt.Errorf("unknown target group: %s", aws.StringValue(input.TargetGroupArn))
+	}
+	result := &elbv2.DescribeTargetHealthOutput{}
+	for _, health := range targets {
+		result.TargetHealthDescriptions = append(result.TargetHealthDescriptions, &elbv2.TargetHealthDescription{TargetHealth: health})
+	}
+	return result, nil
+}
+
+func testTargetHealth(state string, reason string) *elbv2.TargetHealth {
+	health := &elbv2.TargetHealth{State: aws.String(state)}
+	if reason != "" {
+		health.Reason = aws.String(reason)
+	}
+	return health
+}
+
+func TestGetLoadBalancersIdleWithoutHealthyTargets(t *testing.T) {
+	fake := &fakeELBV2{
+		targetGroups: map[string][]string{
+			"no-groups":     {},
+			"no-targets":    {"empty"},
+			"unhealthy":     {"draining", "failing"},
+			"healthy":       {"failing", "serving"},
+			"no-check":      {"unchecked"},
+			"missing-group": {"missing"},
+		},
+		targets: map[string][]*elbv2.TargetHealth{
+			"empty":    {},
+			"draining": {testTargetHealth(elbv2.TargetHealthStateEnumDraining, elbv2.TargetHealthReasonEnumTargetDeregistrationInProgress)},
+			"failing": {
+				testTargetHealth(elbv2.TargetHealthStateEnumUnhealthy, elbv2.TargetHealthReasonEnumTargetFailedHealthChecks),
+				testTargetHealth(elbv2.TargetHealthStateEnumInitial, elbv2.TargetHealthReasonEnumElbRegistrationInProgress),
+			},
+			"serving":   {testTargetHealth(elbv2.TargetHealthStateEnumHealthy, "")},
+			"unchecked": {testTargetHealth(elbv2.TargetHealthStateEnumUnavailable, elbv2.TargetHealthReasonEnumTargetHealthCheckDisabled)},
+		},
+	}
+
+	resources, err := GetLoadBalancers(aws.BackgroundContext(), &fakeELB{}, fake, "us-east-1", "123456789012")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{
+		"no-groups":  true,
+		"no-targets": true,
+		"unhealthy":  true,
+		"healthy":    false,
+		"no-check":   false,
+		// A load balancer whose targets can not be listed is assumed to be serving
+		"missing-group": false,
+	}
+	if len(resources) != len(expected) {
+		t.Fatalf("expected %d load balancers, got %+v", len(expected), resources)
+	}
+	for _, res := range resources {
+		if res.Idle != expected[res.ID] {
+			t.Errorf("expected %s to be idle %v, got %v", res.ID, expected[res.ID], res.Idle)
+		}
+	}
+}
+
+func TestGetLoadBalancersKeysClassicLoadBalancersByArn(t *testing.T) {
+	classic := &fakeELB{tags: map[string]string{"staging": "a", "production": "b"}}
+	modern := &fakeELBV2{targetGroups: map[string][]string{}}
+
+	resources, err := GetLoadBalancers(aws.BackgroundContext(), classic, modern, "eu-west-1", "123456789012")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := make(map[string]string)
+	for _, res := range resources {
+		tags[res.ID] = res.Tags
+	}
+	expected := map[string]string{
+		"arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/staging":    "team:a ",
+		"arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/production": "team:b ",
+	}
+	if fmt.Sprint(tags) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, tags)
+	}
+}
+
+func TestNetworkCostLeavesOutUnpricedResources(t *testing.T) {
+	resources := []NetworkResourceInfo{
+		{ID: "eip-priced", ResourceType: ResourceTypeElasticIP, Idle: true, HoursUp: 10, Region: DefaultRegion},
+		{ID: "eip-elsewhere", ResourceType: ResourceTypeElasticIP, Idle: true, HoursUp: 10, Region: "eu-west-1"},
+		{ID: "lb-unknown", ResourceType: ResourceTypeLoadBalancer, LoadBalancerType: "x9", HoursUp: 10, Region: DefaultRegion},
+	}
+
+	if cost := CalculateNetworkCost(resources); cost != 0.005*10 {
+		t.Errorf("expected the priced Elastic IP to cost %v, got %v", 0.005*10, cost)
+	}
+	summary := CreateNetworkSummary(resources)
+	if eip := summary[ResourceTypeElasticIP]; eip.Number != 2 || eip.NumberIdle != 2 || eip.NumberUnpriced != 1 ||
+		eip.CostPerHour != 0.005 || eip.IdleCostPerHour != 0.005 {
+		t.Errorf("expected both Elastic IPs counted with one unpriced, got %+v", eip)
+	}
+	if lb := summary[networkCategory(ResourceTypeLoadBalancer, "x9")]; lb.Number != 1 || lb.NumberUnpriced != 1 {
+		t.Errorf("expected the load balancer of unknown type counted as unpriced, got %+v", lb)
+	}
+}
@@ -0,0 +1,337 @@
+package overlook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenStackConfig is read from the "openstack" section of the config file
+type OpenStackConfig struct {
+	// AuthURL is the Keystone v3 endpoint, such as https://keystone.example.com:5000/v3
+	AuthURL    string `mapstructure:"auth_url"`
+	Username   string `mapstructure:"username"`
+	Password   string `mapstructure:"password"`
+	UserDomain string `mapstructure:"user_domain"`
+	// Regions limits sampling to these regions, by default every region with a compute endpoint is sampled
+	Regions  []string           `mapstructure:"regions"`
+	Projects []OpenStackProject `mapstructure:"projects"`
+	Rates    []ChargebackRate   `mapstructure:"rates"`
+	// ComputeURL replaces the compute endpoints of the service catalog, used to sample a local fake Nova API
+	ComputeURL string `mapstructure:"compute_url"`
+}
+
+// OpenStackProject is a project to sample, identified by ID or by name and domain
+type OpenStackProject struct {
+	Name   string `mapstructure:"name"`
+	ID     string `mapstructure:"id"`
+	Domain string `mapstructure:"domain"`
+}
+
+// ChargebackRate is the internal hourly rate of a flavor
+type ChargebackRate struct {
+	Flavor string  `mapstructure:"flavor"`
+	Rate   float64 `mapstructure:"rate"`
+}
+
+// HTTPError is returned when an OpenStack API answers with an unexpected status
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// KeystoneToken is a project scoped token along with the compute endpoints of its service catalog
+type KeystoneToken struct {
+	Token       string
+	ProjectID   string
+	ProjectName string
+	// ComputeEndpoints are the public compute endpoints keyed by region
+	ComputeEndpoints map[string]string
+}
+
+type keystoneName struct {
+	Name string `json:"name"`
+}
+
+type keystoneProjectScope struct {
+	ID     string        `json:"id,omitempty"`
+	Name   string        `json:"name,omitempty"`
+	Domain *keystoneName `json:"domain,omitempty"`
+}
+
+type keystoneAuthRequest struct {
+	Auth struct {
+		Identity struct {
+			Methods  []string `json:"methods"`
+			Password struct {
+				User struct {
+					Name     string       `json:"name"`
+					Domain   keystoneName `json:"domain"`
+					Password string       `json:"password"`
+				} `json:"user"`
+			} `json:"password"`
+		} `json:"identity"`
+		Scope struct {
+			Project keystoneProjectScope `json:"project"`
+		} `json:"scope"`
+	} `json:"auth"`
+}
+
+type keystoneAuthResponse struct {
+	Token struct {
+		Project struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"project"`
+		Catalog []struct {
+			Type      string `json:"type"`
+			Endpoints []struct {
+				Interface string `json:"interface"`
+				Region    string `json:"region"`
+				URL       string `json:"url"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	} `json:"token"`
+}
+
+// AuthenticateOpenStack requests a token scoped to the project from Keystone
+func AuthenticateOpenStack(ctx context.Context, httpClient *http.Client, cfg OpenStackConfig, project OpenStackProject) (KeystoneToken, error) {
+	var token KeystoneToken
+	var authReq keystoneAuthRequest
+	authReq.Auth.Identity.Methods = []string{"password"}
+	authReq.Auth.Identity.Password.User.Name = cfg.Username
+	authReq.Auth.Identity.Password.User.Domain.Name = defaultDomain(cfg.UserDomain)
+	authReq.Auth.Identity.Password.User.Password = cfg.Password
+	if project.ID != "" {
+		authReq.Auth.Scope.Project.ID = project.ID
+	} else {
+		authReq.Auth.Scope.Project.Name = project.Name
+		authReq.Auth.Scope.Project.Domain = &keystoneName{Name: defaultDomain(project.Domain)}
+	}
+	body, err := json.Marshal(authReq)
+	if err != nil {
+		return token, err
+	}
+
+	url := strings.TrimSuffix(cfg.AuthURL, "/") + "/auth/tokens"
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return token, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var authResp keystoneAuthResponse
+	header, err := doJSON(httpClient, req.WithContext(ctx), &authResp)
+	if err != nil {
+		return token, err
+	}
+
+	token.Token = header.Get("X-Subject-Token")
+	token.ProjectID = authResp.Token.Project.ID
+	token.ProjectName = authResp.Token.Project.Name
+	token.ComputeEndpoints = make(map[string]string)
+	for _, service := range authResp.Token.Catalog {
+		if service.Type != "compute" {
+			continue
+		}
+		for _, e := range service.Endpoints {
+			if e.Interface == "public" {
+				token.ComputeEndpoints[e.Region] = e.URL
+			}
+		}
+	}
+	return token, nil
+}
+
+func defaultDomain(domain string) string {
+	if domain == "" {
+		return "Default"
+	}
+	return domain
+}
+
+// doJSON sends req and decodes a successful JSON response into v
+func doJSON(httpClient *http.Client, req *http.Request, v interface{}) (http.Header, error) {
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer CheckClose(resp.Body)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp.Header, json.Unmarshal(body, v)
+}
+
+// NovaClient calls the compute API of a single region with a project scoped token
+type NovaClient struct {
+	HTTPClient *http.Client
+	Endpoint   string
+	Token      string
+}
+
+// NovaServer is the part of a Nova server we care about
+type NovaServer struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Status  string    `json:"status"`
+	Created time.Time `json:"created"`
+	// LaunchedAt is when the server was last started, in UTC without a time zone
+	LaunchedAt string `json:"OS-SRV-USG:launched_at"`
+	Flavor     struct {
+		ID string `json:"id"`
+	} `json:"flavor"`
+	Metadata         map[string]string `json:"metadata"`
+	AvailabilityZone string            `json:"OS-EXT-AZ:availability_zone"`
+	TenantID         string            `json:"tenant_id"`
+}
+
+// novaLaunchedAtLayout is the layout of OS-SRV-USG:launched_at
+const novaLaunchedAtLayout = "2006-01-02T15:04:05.000000"
+
+type novaLink struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+func (c NovaClient) get(ctx context.Context, url string, v interface{}) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = strings.TrimSuffix(c.Endpoint, "/") + url
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", c.Token)
+	_, err = doJSON(c.HTTPClient, req.WithContext(ctx), v)
+	return err
+}
+
+// ListServers returns every server of the project, following the pages Nova returns
+func (c NovaClient) ListServers(ctx context.Context) ([]NovaServer, error) {
+	servers := make([]NovaServer, 0)
+	url := "/servers/detail"
+	for url != "" {
+		var page struct {
+			Servers []NovaServer `json:"servers"`
+			Links   []novaLink   `json:"servers_links"`
+		}
+		if err := c.get(ctx, url, &page); err != nil {
+			return nil, err
+		}
+		servers = append(servers, page.Servers...)
+		url = ""
+		for _, l := range page.Links {
+			if l.Rel == "next" {
+				url = l.Href
+			}
+		}
+	}
+	return servers, nil
+}
+
+// ListFlavors returns the names of the public and private flavors keyed by flavor ID
+func (c NovaClient) ListFlavors(ctx context.Context) (map[string]string, error) {
+	flavors := make(map[string]string)
+	url := "/flavors/detail?is_public=None"
+	for url != "" {
+		var page struct {
+			Flavors []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"flavors"`
+			Links []novaLink `json:"flavors_links"`
+		}
+		if err := c.get(ctx, url, &page); err != nil {
+			return nil, err
+		}
+		for _, f := range page.Flavors {
+			flavors[f.ID] = f.Name
+		}
+		url = ""
+		for _, l := range page.Links {
+			if l.Rel == "next" {
+				url = l.Href
+			}
+		}
+	}
+	return flavors, nil
+}
+
+// novaInstanceState maps the status of a Nova server onto the shared instance states,
+// statuses with no equivalent such as ERROR are kept in lower case and are not billed
+func novaInstanceState(status string) string {
+	switch status {
+	case "ACTIVE":
+		return InstanceStateRunning
+	case "BUILD", "REBUILD", "REBOOT", "HARD_REBOOT", "MIGRATING", "RESIZE", "VERIFY_RESIZE", "REVERT_RESIZE":
+		return InstanceStatePending
+	case "SHUTOFF", "SUSPENDED", "PAUSED", "SHELVED", "SHELVED_OFFLOADED", "RESCUE":
+		return InstanceStateStopped
+	case "DELETED", "SOFT_DELETED":
+		return InstanceStateTerminated
+	}
+	return strings.ToLower(status)
+}
+
+// GetOpenStackInstances returns the servers of a project in a region, in every state, ordered by HoursUp
+func GetOpenStackInstances(ctx context.Context, nova NovaClient, region string) ([]InstanceInfo, error) {
+	flavors, err := nova.ListFlavors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := nova.ListServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]InstanceInfo, 0)
+	for _, s := range servers {
+		var info InstanceInfo
+		info.ID = s.ID
+		info.Provider = ProviderOpenStack
+		info.State = novaInstanceState(s.Status)
+		// Flavors deleted since the server was created are only known by ID
+		info.InstanceType = s.Flavor.ID
+		if name, ok := flavors[s.Flavor.ID]; ok {
+			info.InstanceType = name
+		}
+		if info.State == InstanceStateRunning {
+			started := s.Created
+			if launchedAt, err := time.Parse(novaLaunchedAtLayout, s.LaunchedAt); err == nil {
+				started = launchedAt
+			}
+			info.HoursUp = hoursSince(started)
+		}
+		keys := make([]string, 0)
+		for k := range s.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		tags := fmt.Sprintf("Name:%s ", s.Name)
+		for _, k := range keys {
+			tags += fmt.Sprintf("%s:%s ", k, s.Metadata[k])
+		}
+		info.Tags = tags
+		info.AvailabilityZone = s.AvailabilityZone
+		info.Region = region
+		instances = append(instances, info)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].HoursUp > instances[j].HoursUp })
+	return instances, nil
+}
@@ -0,0 +1,198 @@
+package overlook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testToken = "test-token"
+
+// fakeOpenStack serves Keystone under /v3 and Nova under /compute, servers are listed two to a page
+type fakeOpenStack struct {
+	t        *testing.T
+	url      string
+	launched time.Time
+	auth     keystoneAuthRequest
+}
+
+func (f *fakeOpenStack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v3/auth/tokens" {
+		f.serveToken(w, r)
+		return
+	}
+	if r.Header.Get("X-Auth-Token") != testToken {
+		http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/compute/flavors/detail":
+		if r.URL.Query().Get("is_public") != "None" {
+			f.t.Errorf("private flavors were not requested: %s", r.URL)
+		}
+		fmt.Fprint(w, `{"flavors": [{"id": "1", "name": "m1.small"}, {"id": "2", "name": "m1.large"}]}`)
+	case "/compute/servers/detail":
+		launchedAt := f.launched.UTC().Format(novaLaunchedAtLayout)
+		switch r.URL.Query().Get("marker") {
+		case "":
+			fmt.Fprintf(w, `{"servers": [
+				{"id": "s-1", "name": "web", "status": "ACTIVE", "created": "2020-01-01T00:00:00Z",
+				 "OS-SRV-USG:launched_at": %q, "flavor": {"id": "1"}, "metadata": {"team": "a", "env": "prod"},
+				 "OS-EXT-AZ:availability_zone": "nova"},
+				{"id": "s-2", "name": "db", "status": "SHUTOFF", "created": "2020-01-01T00:00:00Z", "flavor": {"id": "2"}}
+			], "servers_links": [{"rel": "next", "href": "%s/compute/servers/detail?marker=s-2"}]}`, launchedAt, f.url)
+		case "s-2":
+			fmt.Fprint(w, `{"servers": [
+				{"id": "s-3", "name": "old", "status": "VERIFY_RESIZE", "created": "2020-01-01T00:00:00Z", "flavor": {"id": "deleted"}}
+			]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeOpenStack) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f.auth = keystoneAuthRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&f.auth); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.auth.Auth.Identity.Password.User.Password != "secret" {
+		http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("X-Subject-Token", testToken)
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"token": {"project": {"id": "p-1", "name": "demo"}, "catalog": [
+		{"type": "identity", "endpoints": [{"interface": "public", "region": "RegionOne", "url": "%[1]s/v3"}]},
+		{"type": "compute", "endpoints": [
+			{"interface": "public", "region": "RegionOne", "url": "%[1]s/compute"},
+			{"interface": "internal", "region": "RegionOne", "url": "http://internal.invalid/compute"}
+		]}
+	]}}`, f.url)
+}
+
+func newFakeOpenStack(t *testing.T) (*fakeOpenStack, *httptest.Server) {
+	fake := &fakeOpenStack{t: t, launched: time.Now().Add(-3 * time.Hour)}
+	srv := httptest.NewServer(fake)
+	fake.url = srv.URL
+	return fake, srv
+}
+
+func TestAuthenticateOpenStack(t *testing.T) {
+	fake, srv := newFakeOpenStack(t)
+	defer srv.Close()
+
+	cfg := OpenStackConfig{AuthURL: srv.URL + "/v3/", Username: "admin", Password: "secret"}
+	token, err := AuthenticateOpenStack(context.Background(), srv.Client(), cfg, OpenStackProject{Name: "demo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token != testToken || token.ProjectID != "p-1" || token.ProjectName != "demo" {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if len(token.ComputeEndpoints) != 1 || token.ComputeEndpoints["RegionOne"] != srv.URL+"/compute" {
+		t.Errorf("expected only the public compute endpoint, got %v", token.ComputeEndpoints)
+	}
+
+	auth := fake.auth.Auth
+	if auth.Identity.Password.User.Name != "admin" || auth.Identity.Password.User.Domain.Name != "Default" {
+		t.Errorf("unexpected user %+v", auth.Identity.Password.User)
+	}
+	scope := auth.Scope.Project
+	if scope.Name != "demo" || scope.ID != "" || scope.Domain == nil || scope.Domain.Name != "Default" {
+		t.Errorf("unexpected project scope %+v", scope)
+	}
+
+	cfg.Password = "wrong"
+	_, err = AuthenticateOpenStack(context.Background(), srv.Client(), cfg, OpenStackProject{ID: "p-1"})
+	if httpErr, ok := err.(*HTTPError); !ok || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an HTTPError with status 401, got %v", err)
+	}
+	if fake.auth.Auth.Scope.Project.ID != "p-1" || fake.auth.Auth.Scope.Project.Domain != nil {
+		t.Errorf("a project ID is scoped without a domain, got %+v", fake.auth.Auth.Scope.Project)
+	}
+}
+
+func TestGetOpenStackInstances(t *testing.T) {
+	_, srv := newFakeOpenStack(t)
+	defer srv.Close()
+
+	cfg := OpenStackConfig{AuthURL: srv.URL + "/v3", Username: "admin", Password: "secret"}
+	token, err := AuthenticateOpenStack(context.Background(), srv.Client(), cfg, OpenStackProject{ID: "p-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nova := NovaClient{HTTPClient: srv.Client(), Endpoint: token.ComputeEndpoints["RegionOne"], Token: token.Token}
+	instances, err := GetOpenStackInstances(context.Background(), nova, "RegionOne")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 3 {
+		t.Fatalf("expected the servers of both pages, got %+v", instances)
+	}
+
+	byID := make(map[string]InstanceInfo)
+	for _, inst := range instances {
+		byID[inst.ID] = inst
+		if inst.Provider != ProviderOpenStack || inst.Region != "RegionOne" {
+			t.Errorf("unexpected instance %+v", inst)
+		}
+	}
+	web := byID["s-1"]
+	if web.State != InstanceStateRunning || web.InstanceType != "m1.small" || web.AvailabilityZone != "nova" {
+		t.Errorf("unexpected running server %+v", web)
+	}
+	// Hours are counted from launched_at rather than created
+	if web.HoursUp < 2.9 || web.HoursUp > 3.1 {
+		t.Errorf("expected the running server to be up 3 hours, got %.2f", web.HoursUp)
+	}
+	if web.Tags != "Name:web env:prod team:a " {
+		t.Errorf("unexpected tags %q", web.Tags)
+	}
+	db := byID["s-2"]
+	if db.State != InstanceStateStopped || db.InstanceType != "m1.large" || db.HoursUp != 0 {
+		t.Errorf("unexpected stopped server %+v", db)
+	}
+	old := byID["s-3"]
+	if old.State != InstanceStatePending || old.InstanceType != "deleted" {
+		t.Errorf("expected a server with a deleted flavor to keep the flavor ID, got %+v", old)
+	}
+
+	nova.Token = "expired"
+	_, err = GetOpenStackInstances(context.Background(), nova, "RegionOne")
+	if httpErr, ok := err.(*HTTPError); !ok || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an HTTPError with status 401, got %v", err)
+	}
+}
+
+func TestNovaInstanceState(t *testing.T) {
+	tests := map[string]string{
+		"ACTIVE":            InstanceStateRunning,
+		"BUILD":             InstanceStatePending,
+		"HARD_REBOOT":       InstanceStatePending,
+		"VERIFY_RESIZE":     InstanceStatePending,
+		"SHUTOFF":           InstanceStateStopped,
+		"SHELVED_OFFLOADED": InstanceStateStopped,
+		"RESCUE":            InstanceStateStopped,
+		"DELETED":           InstanceStateTerminated,
+		"SOFT_DELETED":      InstanceStateTerminated,
+		"ERROR":             "error",
+		"UNKNOWN":           "unknown",
+	}
+	for status, expected := range tests {
+		if state := novaInstanceState(status); state != expected {
+			t.Errorf("novaInstanceState(%q) = %q, expected %q", status, state, expected)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package overlook
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultCurrency is the currency of AWS prices
+const DefaultCurrency = "USD"
+
+// PricingConfig adjusts list prices to what is actually paid, read from the "pricing" section of the config file
+type PricingConfig struct {
+	// Overrides replace the hourly rate of instance types, such as internal chargeback rates
+	Overrides []PriceOverride `mapstructure:"overrides"`
+	// Discount is a percentage taken off the cost of every account without its own discount
+	Discount float64 `mapstructure:"discount"`
+	// AccountDiscounts are percentages taken off the cost of single accounts
+	AccountDiscounts []AccountDiscount `mapstructure:"account_discounts"`
+	// Currency reports are shown in, ExchangeRate is the amount of the currency for one US dollar
+	Currency     string  `mapstructure:"currency"`
+	ExchangeRate float64 `mapstructure:"exchange_rate"`
+}
+
+// PriceOverride is the hourly rate of an instance type, in every region unless Region is set
+type PriceOverride struct {
+	InstanceType string  `mapstructure:"instance_type"`
+	Region       string  `mapstructure:"region"`
+	Rate         float64 `mapstructure:"rate"`
+}
+
+// AccountDiscount is a percentage taken off the cost of an account
+type AccountDiscount struct {
+	Account  string  `mapstructure:"account"`
+	Discount float64 `mapstructure:"discount"`
+}
+
+// activePricingConfig applies to the effective costs of reports
+var activePricingConfig = PricingConfig{Currency: DefaultCurrency, ExchangeRate: 1}
+
+// SetPricingConfig replaces the adjustments applied to the effective costs of reports
+func SetPricingConfig(cfg PricingConfig) error {
+	if cfg.Currency == "" {
+		cfg.Currency = DefaultCurrency
+	}
+	if cfg.ExchangeRate == 0 {
+		if cfg.Currency != DefaultCurrency {
+			return fmt.Errorf("pricing.exchange_rate is needed to show costs in %s", cfg.Currency)
+		}
+		cfg.ExchangeRate = 1
+	}
+	if cfg.ExchangeRate < 0 {
+		return fmt.Errorf("pricing.exchange_rate must be positive")
+	}
+	if cfg.Discount < 0 || cfg.Discount > 100 {
+		return fmt.Errorf("pricing.discount must be a percentage between 0 and 100")
+	}
+	for _, d := range cfg.AccountDiscounts {
+		if d.Discount < 0 || d.Discount > 100 {
+			return fmt.Errorf("discount of account %s must be a percentage between 0 and 100", d.Account)
+		}
+	}
+	for _, o := range cfg.Overrides {
+		if o.InstanceType == "" || o.Rate <= 0 {
+			return fmt.Errorf("price overrides need an instance_type and a rate")
+		}
+	}
+	activePricingConfig = cfg
+	return nil
+}
+
+// overrideCostPerHour returns the overridden rate of an instance type in a region, an override
+// for the region is preferred over one for every region
+func overrideCostPerHour(instanceType string, region string) (float64, bool) {
+	cost, found := 0.0, false
+	for _, o := range activePricingConfig.Overrides {
+		if o.InstanceType != instanceType {
+			continue
+		}
+		if o.Region == region {
+			return o.Rate, true
+		}
+		if o.Region == "" {
+			cost, found = o.Rate, true
+		}
+	}
+	return cost, found
+}
+
+// accountDiscount returns the percentage taken off the cost of an account
+func accountDiscount(account string) float64 {
+	for _, d := range activePricingConfig.AccountDiscounts {
+		if d.Account == account {
+			return d.Discount
+		}
+	}
+	return activePricingConfig.Discount
+}
+
+// effectiveSnapshotCostPerHour returns the rate an instance is charged at after overrides,
+// spot prices are never overridden
+func effectiveSnapshotCostPerHour(at time.Time, instanceEntry BillingSnapshot) (float64, error) {
+	if !instanceEntry.IsSpot() {
+		if cost, ok := overrideCostPerHour(instanceEntry.InstanceType, instanceEntry.Region); ok {
+			return cost, nil
+		}
+	}
+	return snapshotCostPerHour(at, instanceEntry)
+}
+
+// scaleCosts converts the costs of the report from US dollars at rate
+func (r *ReportDaily) scaleCosts(rate float64) {
+	for _, cost := range []*float64{&r.Cost, &r.InstanceCost, &r.VolumeCost, &r.UnattachedVolumeCost, &r.NetworkCost,
+		&r.ElasticIPCost, &r.NatGatewayCost, &r.LoadBalancerCost, &r.IdleNetworkCost, &r.DBCost,
+		&r.EffectiveInstanceCost, &r.EffectiveCost} {
+		*cost = *cost * rate
+	}
+	for region, reportByRegion := range r.Regions {
+		reportByRegion.scaleCosts(rate)
+		r.Regions[region] = reportByRegion
+	}
+	for account, reportByAccount := range r.Accounts {
+		reportByAccount.Cost = reportByAccount.Cost * rate
+		reportByAccount.EffectiveCost = reportByAccount.EffectiveCost * rate
+		for region, reportByRegion := range reportByAccount.Regions {
+			reportByRegion.scaleCosts(rate)
+			reportByAccount.Regions[region] = reportByRegion
+		}
+		r.Accounts[account] = reportByAccount
+	}
+	for i := range r.Reservations {
+		r.Reservations[i].Cost = r.Reservations[i].Cost * rate
+		if r.Reservations[i].Type == ReservationTypeSavingsPlan {
+			r.Reservations[i].Capacity = r.Reservations[i].Capacity * rate
+			r.Reservations[i].Used = r.Reservations[i].Used * rate
+		}
+	}
+}
+
+// scaleCosts converts the costs of the region from US dollars at rate
+func (r *ReportByRegion) scaleCosts(rate float64) {
+	for _, cost := range []*float64{&r.Cost, &r.InstanceCost, &r.VolumeCost, &r.NetworkCost, &r.DBCost, &r.EffectiveCost} {
+		*cost = *cost * rate
+	}
+	for k, v := range r.InstanceTypes {
+		v.Cost = v.Cost * rate
+		v.EffectiveCost = v.EffectiveCost * rate
+		r.InstanceTypes[k] = v
+	}
+	for k, v := range r.VolumeTypes {
+		v.Cost = v.Cost * rate
+		v.UnattachedCost = v.UnattachedCost * rate
+		r.VolumeTypes[k] = v
+	}
+	for k, v := range r.NetworkTypes {
+		v.Cost = v.Cost * rate
+		v.IdleCost = v.IdleCost * rate
+		r.NetworkTypes[k] = v
+	}
+	for k, v := range r.DBInstanceClasses {
+		v.Cost = v.Cost * rate
+		r.DBInstanceClasses[k] = v
+	}
+}
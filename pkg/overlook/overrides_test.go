@@ -0,0 +1,190 @@
+package overlook
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// usePricingConfig applies cfg to reports until the test ends
+func usePricingConfig(t *testing.T, cfg PricingConfig) {
+	previous := activePricingConfig
+	if err := SetPricingConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { activePricingConfig = previous })
+}
+
+// useReservations applies reservations to reports until the test ends
+func useReservations(t *testing.T, reservations []Reservation) {
+	previous := activeReservations
+	if err := SetReservations(reservations); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { activeReservations = previous })
+}
+
+func TestOverrideCostPerHour(t *testing.T) {
+	global := PriceOverride{InstanceType: "m5.large", Rate: 0.05}
+	regional := PriceOverride{InstanceType: "m5.large", Region: "us-east-1", Rate: 0.08}
+
+	tests := []struct {
+		name         string
+		overrides    []PriceOverride
+		instanceType string
+		region       string
+		expected     float64
+		found        bool
+	}{
+		{"region override wins listed last", []PriceOverride{global, regional}, "m5.large", "us-east-1", 0.08, true},
+		{"region override wins listed first", []PriceOverride{regional, global}, "m5.large", "us-east-1", 0.08, true},
+		{"global override elsewhere", []PriceOverride{regional, global}, "m5.large", "eu-west-1", 0.05, true},
+		{"region override only", []PriceOverride{regional}, "m5.large", "eu-west-1", 0, false},
+		{"other instance type", []PriceOverride{regional, global}, "m5.xlarge", "us-east-1", 0, false},
+	}
+	for _, tc := range tests {
+		usePricingConfig(t, PricingConfig{Overrides: tc.overrides})
+		cost, found := overrideCostPerHour(tc.instanceType, tc.region)
+		if cost != tc.expected || found != tc.found {
+			t.Errorf("%s: expected %v %v, got %v %v", tc.name, tc.expected, tc.found, cost, found)
+		}
+	}
+}
+
+func TestAccountDiscount(t *testing.T) {
+	usePricingConfig(t, PricingConfig{Discount: 10, AccountDiscounts: []AccountDiscount{{Account: "111", Discount: 50}, {Account: "333", Discount: 0}}})
+
+	for account, expected := range map[string]float64{"111": 50, "222": 10, "333": 0, "": 10} {
+		if discount := accountDiscount(account); discount != expected {
+			t.Errorf("expected account %q to get %v%% off, got %v%%", account, expected, discount)
+		}
+	}
+}
+
+func TestReportEffectiveCost(t *testing.T) {
+	usePriceList(t, nil)
+	defer UseSpotPriceHistory(activeSpotPrices)
+	UseSpotPriceHistory(NewSpotPriceHistory())
+	usePricingConfig(t, PricingConfig{
+		Overrides: []PriceOverride{
+			{InstanceType: "m5.large", Region: "us-east-1", Rate: 0.08},
+			{InstanceType: "m5.large", Rate: 0.05},
+		},
+		Discount:         10,
+		AccountDiscounts: []AccountDiscount{{Account: "111", Discount: 50}},
+	})
+
+	report := testReport(t,
+		BillingSnapshot{ID: "i-1", AccountID: "111", InstanceType: "m5.large", Region: "us-east-1", AvailabilityZone: "us-east-1a"},
+		BillingSnapshot{ID: "vol-1", AccountID: "111", ResourceType: ResourceTypeVolume, VolumeType: "gp2", SizeGB: 100, AttachedTo: "i-1", Region: "us-east-1"},
+		BillingSnapshot{ID: "i-2", AccountID: "222", InstanceType: "m5.large", Region: "us-east-1", AvailabilityZone: "us-east-1a"},
+		// Spot prices are never overridden
+		BillingSnapshot{ID: "i-3", AccountID: "222", InstanceType: "m5.large", Region: "us-east-1", AvailabilityZone: "us-east-1a",
+			Lifecycle: LifecycleSpot, CostPerHour: 0.03},
+	)
+	volumeCost, err := GetVolumeCostPerHour("us-east-1", "gp2", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]struct{ cost, effective float64 }{
+		"111": {0.096 + volumeCost, (0.08 + volumeCost) * 0.5},
+		"222": {0.096 + 0.03, (0.08 + 0.03) * 0.9},
+	}
+	for account, e := range expected {
+		a := report.Accounts[account]
+		if math.Abs(a.Cost-e.cost) > 1e-9 || math.Abs(a.EffectiveCost-e.effective) > 1e-9 {
+			t.Errorf("expected account %s to cost %v and %v effective, got %v and %v", account, e.cost, e.effective, a.Cost, a.EffectiveCost)
+		}
+	}
+	if effective := 0.08*0.5 + 0.08*0.9 + 0.03*0.9; math.Abs(report.EffectiveInstanceCost-effective) > 1e-9 {
+		t.Errorf("expected instances to cost %v effective, got %v", effective, report.EffectiveInstanceCost)
+	}
+	if effective := 0.08*0.5 + 0.08*0.9 + 0.03*0.9 + volumeCost*0.5; math.Abs(report.EffectiveCost-effective) > 1e-9 {
+		t.Errorf("expected %v effective cost, got %v", effective, report.EffectiveCost)
+	}
+	if cost := 0.096*2 + 0.03 + volumeCost; math.Abs(report.Cost-cost) > 1e-9 {
+		t.Errorf("expected the list cost to leave out overrides and discounts, got %v rather than %v", report.Cost, cost)
+	}
+}
+
+func TestReportScalesEveryCostToCurrency(t *testing.T) {
+	usePriceList(t, nil)
+	useReservations(t, []Reservation{
+		{Name: "ri", Type: ReservationTypeReservedInstance, InstanceType: "m5.large", Region: "us-east-1", Count: 1, Term: "1y", Hourly: 0.05},
+		{Name: "sp", Type: ReservationTypeSavingsPlan, Term: "1y", Hourly: 0.01, Discount: 30},
+	})
+	snapshots := []BillingSnapshot{
+		{ID: "i-1", AccountID: "111", InstanceType: "m5.large", Region: "us-east-1", AvailabilityZone: "us-east-1a"},
+		{ID: "i-2", AccountID: "222", InstanceType: "m5.xlarge", Region: "us-east-1", AvailabilityZone: "us-east-1a"},
+		{ID: "vol-1", AccountID: "111", ResourceType: ResourceTypeVolume, VolumeType: "gp2", SizeGB: 100, Region: "us-east-1"},
+		{ID: "eip-1", AccountID: "111", ResourceType: ResourceTypeElasticIP, Idle: true, Region: "us-east-1"},
+		{ID: "nat-1", AccountID: "222", ResourceType: ResourceTypeNatGateway, Idle: true, Region: "us-east-1"},
+		{ID: "lb-1", AccountID: "222", ResourceType: ResourceTypeLoadBalancer, LoadBalancerType: LoadBalancerTypeApplication,
+			Idle: true, Region: "us-east-1"},
+		{ID: "db-1", AccountID: "111", ResourceType: ResourceTypeDBInstance, DBInstanceClass: "db.t2.micro", SizeGB: 20,
+			State: "available", Region: "us-east-1"},
+	}
+	usePricingConfig(t, PricingConfig{Discount: 10})
+	usd := testReport(t, snapshots...)
+	usePricingConfig(t, PricingConfig{Discount: 10, Currency: "EUR", ExchangeRate: 2})
+	eur := testReport(t, snapshots...)
+
+	if eur.Currency != "EUR" {
+		t.Errorf("expected the report in EUR, got %s", eur.Currency)
+	}
+	fields, priced := make(map[string]bool), make(map[string]bool)
+	compareScaledCosts(t, "report", reflect.ValueOf(usd), reflect.ValueOf(eur), 2, fields, priced)
+	for field := range fields {
+		if !priced[field] {
+			t.Errorf("expected the test report to have some %s to scale", field)
+		}
+	}
+}
+
+// compareScaledCosts checks every cost found in usd is scaled by rate in scaled and every other number is
+// left alone, fields lists the cost fields found and priced those with a cost
+func compareScaledCosts(t *testing.T, path string, usd reflect.Value, scaled reflect.Value, rate float64, fields map[string]bool, priced map[string]bool) {
+	t.Helper()
+	switch usd.Kind() {
+	case reflect.Struct:
+		if usd.Type() == reflect.TypeOf(time.Time{}) {
+			return
+		}
+		reservation, _ := usd.Interface().(ReportReservation)
+		for i := 0; i < usd.NumField(); i++ {
+			field := usd.Type().Field(i)
+			fieldPath := path + "." + field.Name
+			if field.Type.Kind() != reflect.Float64 {
+				compareScaledCosts(t, fieldPath, usd.Field(i), scaled.Field(i), rate, fields, priced)
+				continue
+			}
+			expected := usd.Field(i).Float()
+			isCost := strings.HasSuffix(field.Name, "Cost") ||
+				(reservation.Type == ReservationTypeSavingsPlan && (field.Name == "Capacity" || field.Name == "Used"))
+			if isCost {
+				expected = expected * rate
+				name := usd.Type().Name() + "." + field.Name
+				fields[name] = true
+				priced[name] = priced[name] || expected != 0
+			}
+			if math.Abs(scaled.Field(i).Float()-expected) > 1e-9 {
+				t.Errorf("expected %s to be %v, got %v", fieldPath, expected, scaled.Field(i).Float())
+			}
+		}
+	case reflect.Map:
+		for _, key := range usd.MapKeys() {
+			compareScaledCosts(t, path+"["+key.String()+"]", usd.MapIndex(key), scaled.MapIndex(key), rate, fields, priced)
+		}
+	case reflect.Slice:
+		if usd.Len() != scaled.Len() {
+			t.Errorf("expected %d of %s, got %d", usd.Len(), path, scaled.Len())
+			return
+		}
+		for i := 0; i < usd.Len(); i++ {
+			compareScaledCosts(t, path, usd.Index(i), scaled.Index(i), rate, fields, priced)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package overlook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The price cache holds a version of the EC2 on-demand price list per file
+//	./pricing/$VERSION.json   {"$PriceList"}
+//	./pricing/current         $VERSION
+// Versions are those of the AWS Price List offer files they were imported from.
+
+const currentPriceListFileName = "current"
+
+// Defaults used when the operating system or tenancy of an instance is not known
+const (
+	DefaultRegion          = "us-east-1"
+	DefaultOperatingSystem = "Linux"
+	DefaultTenancy         = "Shared"
+)
+
+// PriceKey identifies an on-demand rate in a PriceList
+type PriceKey struct {
+	Region          string
+	InstanceType    string
+	OperatingSystem string
+	Tenancy         string
+}
+
+func (k PriceKey) String() string {
+	return strings.Join([]string{k.Region, k.InstanceType, k.OperatingSystem, k.Tenancy}, "/")
+}
+
+// usageOperationOperatingSystems maps the usage operation of an instance, which identifies the
+// billing product of its AMI, to the operating system of the price list
+var usageOperationOperatingSystems = map[string]string{
+	"RunInstances":      "Linux",
+	"RunInstances:0002": "Windows",
+	"RunInstances:0010": "RHEL",
+	"RunInstances:1010": "Red Hat Enterprise Linux with HA",
+	"RunInstances:000g": "SUSE",
+	"RunInstances:0004": "Linux with SQL Std",
+	"RunInstances:0100": "Linux with SQL Ent",
+	"RunInstances:0200": "Linux with SQL Web",
+	"RunInstances:0006": "Windows with SQL Std",
+	"RunInstances:0102": "Windows with SQL Ent",
+	"RunInstances:0202": "Windows with SQL Web",
+}
+
+// platformDetailsOperatingSystems maps the platform details of an instance to the operating system
+// of the price list, used when the usage operation is not known
+var platformDetailsOperatingSystems = map[string]string{
+	"Linux/UNIX":                       "Linux",
+	"Windows":                          "Windows",
+	"Red Hat Enterprise Linux":         "RHEL",
+	"Red Hat Enterprise Linux with HA": "Red Hat Enterprise Linux with HA",
+	"SUSE Linux":                       "SUSE",
+}
+
+// PricingOperatingSystem returns the operating system an instance is priced as from its usage operation,
+// platform details or platform, in that order. Instances with none of them are priced as Linux.
+func PricingOperatingSystem(platform string, platformDetails string, usageOperation string) string {
+	if os, ok := usageOperationOperatingSystems[usageOperation]; ok {
+		return os
+	}
+	if os, ok := platformDetailsOperatingSystems[platformDetails]; ok {
+		return os
+	}
+	if strings.EqualFold(platform, "windows") {
+		return "Windows"
+	}
+	return DefaultOperatingSystem
+}
+
+// PricingTenancy returns the tenancy of the price list for the tenancy of an instance placement
+func PricingTenancy(tenancy string) string {
+	switch tenancy {
+	case "dedicated":
+		return "Dedicated"
+	case "host":
+		return "Host"
+	}
+	return DefaultTenancy
+}
+
+// PriceList is a version of the EC2 on-demand hourly rates, keyed by PriceKey.String()
+type PriceList struct {
+	Version         string
+	PublicationDate string
+	ImportedAt      time.Time
+	Prices          map[string]float64
+}
+
+// Lookup returns the hourly rate for key
+func (p *PriceList) Lookup(key PriceKey) (float64, bool) {
+	cost, ok := p.Prices[key.String()]
+	return cost, ok
+}
+
+// activePriceList is used by GetCostPerHour, nil means only the built-in rates are known
+var activePriceList *PriceList
+
+// UsePriceList makes GetCostPerHour look prices up in p
+func UsePriceList(p *PriceList) {
+	activePriceList = p
+}
+
+// BuiltinPriceListVersion is the version recorded for prices from the built-in rates
+const BuiltinPriceListVersion = "built-in"
+
+// ActivePriceListVersion returns the version of the price list used by GetCostPerHour
+func ActivePriceListVersion() string {
+	if activePriceList == nil {
+		return BuiltinPriceListVersion
+	}
+	return activePriceList.Version
+}
+
+// GetPricingDataLocation returns where the price cache will exist
+func GetPricingDataLocation() string {
+	pricingDirName := filepath.Join(".", "pricing")
+	s, _ := filepath.Abs(pricingDirName)
+	return s
+}
+
+func priceListFileName(pricingDirPath string, version string) string {
+	return filepath.Join(pricingDirPath, version+".json")
+}
+
+// isPriceListFileName reports whether a file of the pricing directory holds a version of the price list,
+// rather than the spot prices cached there by earlier versions
+func isPriceListFileName(name string) bool {
+	return filepath.Ext(name) == ".json" && name != ".json" && name != legacySpotPriceHistoryFileName
+}
+
+// ReadPriceList returns a version of the price list from the cache
+func ReadPriceList(pricingDirPath string, version string) (*PriceList, error) {
+	if !isPriceListFileName(filepath.Base(priceListFileName(pricingDirPath, version))) {
+		return nil, fmt.Errorf("unknown price list version: %s", version)
+	}
+	data, err := ioutil.ReadFile(priceListFileName(pricingDirPath, version))
+	if err != nil {
+		return nil, err
+	}
+	var p PriceList
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("unable to parse price list %s: %v", version, err)
+	}
+	if p.Version == "" {
+		return nil, fmt.Errorf("price list %s has no version, it is not a price list", version)
+	}
+	return &p, nil
+}
+
+// WritePriceList stores p in the cache, merging it into an existing list of the same version
+// so the regional offer files of a publication can be imported one after another
+func WritePriceList(pricingDirPath string, p *PriceList) error {
+	if err := os.MkdirAll(pricingDirPath, os.ModePerm); err != nil {
+		return err
+	}
+	if existing, err := ReadPriceList(pricingDirPath, p.Version); err == nil {
+		for k, v := range p.Prices {
+			existing.Prices[k] = v
+		}
+		existing.ImportedAt = p.ImportedAt
+		p = existing
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(priceListFileName(pricingDirPath, p.Version), data, 0660)
+}
+
+// PriceListVersions returns the versions in the cache, oldest first
+func PriceListVersions(pricingDirPath string) ([]string, error) {
+	files, err := ioutil.ReadDir(pricingDirPath)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0)
+	for _, f := range files {
+		if !f.IsDir() && isPriceListFileName(f.Name()) {
+			versions = append(versions, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// CurrentPriceListVersion returns the version prices are looked up in
+func CurrentPriceListVersion(pricingDirPath string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(pricingDirPath, currentPriceListFileName))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SetCurrentPriceListVersion makes version the one prices are looked up in
+func SetCurrentPriceListVersion(pricingDirPath string, version string) error {
+	if _, err := ReadPriceList(pricingDirPath, version); err != nil {
+		return fmt.Errorf("unknown price list version: %s", version)
+	}
+	return ioutil.WriteFile(filepath.Join(pricingDirPath, currentPriceListFileName), []byte(version+"\n"), 0660)
+}
+
+// offerProduct is the part of a product in an offer file we care about
+type offerProduct struct {
+	SKU           string `json:"sku"`
+	ProductFamily string `json:"productFamily"`
+	Attributes    struct {
+		Location        string `json:"location"`
+		RegionCode      string `json:"regionCode"`
+		InstanceType    string `json:"instanceType"`
+		OperatingSystem string `json:"operatingSystem"`
+		Tenancy         string `json:"tenancy"`
+		PreInstalledSw  string `json:"preInstalledSw"`
+		LicenseModel    string `json:"licenseModel"`
+		CapacityStatus  string `json:"capacitystatus"`
+	} `json:"attributes"`
+}
+
+// offerTerm is an on-demand term of a SKU in an offer file
+type offerTerm struct {
+	PriceDimensions map[string]struct {
+		Unit         string            `json:"unit"`
+		PricePerUnit map[string]string `json:"pricePerUnit"`
+	} `json:"priceDimensions"`
+}
+
+// offerLocations maps the location names of offer files written before regionCode was added
+var offerLocations = map[string]string{
+	"US East (N. Virginia)":      "us-east-1",
+	"US East (Ohio)":             "us-east-2",
+	"US West (N. California)":    "us-west-1",
+	"US West (Oregon)":           "us-west-2",
+	"Canada (Central)":           "ca-central-1",
+	"South America (Sao Paulo)":  "sa-east-1",
+	"EU (Ireland)":               "eu-west-1",
+	"EU (London)":                "eu-west-2",
+	"EU (Paris)":                 "eu-west-3",
+	"EU (Frankfurt)":             "eu-central-1",
+	"EU (Stockholm)":             "eu-north-1",
+	"Asia Pacific (Tokyo)":       "ap-northeast-1",
+	"Asia Pacific (Seoul)":       "ap-northeast-2",
+	"Asia Pacific (Osaka-Local)": "ap-northeast-3",
+	"Asia Pacific (Singapore)":   "ap-southeast-1",
+	"Asia Pacific (Sydney)":      "ap-southeast-2",
+	"Asia Pacific (Mumbai)":      "ap-south-1",
+	"AWS GovCloud (US-East)":     "us-gov-east-1",
+	"AWS GovCloud (US)":          "us-gov-west-1",
+}
+
+// priceKey returns the key of a product, ok is false for products which are not the
+// on-demand usage of an instance, such as reservations or BYOL licenses. Instances with
+// SQL Server pre-installed are keyed by operating system with the SQL Server edition.
+func (p offerProduct) priceKey() (PriceKey, bool) {
+	a := p.Attributes
+	if !strings.HasPrefix(p.ProductFamily, "Compute Instance") || a.InstanceType == "" {
+		return PriceKey{}, false
+	}
+	operatingSystem := a.OperatingSystem
+	if a.PreInstalledSw != "" && a.PreInstalledSw != "NA" {
+		operatingSystem = operatingSystem + " with " + a.PreInstalledSw
+	}
+	if a.CapacityStatus != "" && a.CapacityStatus != "Used" {
+		return PriceKey{}, false
+	}
+	if a.LicenseModel == "Bring your own license" {
+		return PriceKey{}, false
+	}
+	region := a.RegionCode
+	if region == "" {
+		region = offerLocations[a.Location]
+	}
+	if region == "" {
+		return PriceKey{}, false
+	}
+	return PriceKey{Region: region, InstanceType: a.InstanceType, OperatingSystem: operatingSystem, Tenancy: a.Tenancy}, true
+}
+
+// ImportOfferFile parses an AWS Price List bulk offer file for EC2 and returns its on-demand
+// hourly rates. The file is streamed as the offer file for every region is several GB.
+func ImportOfferFile(r io.Reader) (*PriceList, error) {
+	p := &PriceList{ImportedAt: time.Now().UTC(), Prices: make(map[string]float64)}
+	keys := make(map[string]PriceKey)
+
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		field, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch field {
+		case "version":
+			err = dec.Decode(&p.Version)
+		case "publicationDate":
+			err = dec.Decode(&p.PublicationDate)
+		case "products":
+			err = decodeObject(dec, func(sku string) error {
+				var product offerProduct
+				if err := dec.Decode(&product); err != nil {
+					return err
+				}
+				if key, ok := product.priceKey(); ok {
+					keys[sku] = key
+				}
+				return nil
+			})
+		case "terms":
+			err = decodeObject(dec, func(termType string) error {
+				if termType != "OnDemand" {
+					return skipValue(dec)
+				}
+				if len(keys) == 0 {
+					return fmt.Errorf("no products precede the terms of the offer file")
+				}
+				return decodeObject(dec, func(sku string) error {
+					var terms map[string]offerTerm
+					if err := dec.Decode(&terms); err != nil {
+						return err
+					}
+					key, ok := keys[sku]
+					if !ok {
+						return nil
+					}
+					for _, term := range terms {
+						for _, dimension := range term.PriceDimensions {
+							if dimension.Unit != "Hrs" {
+								continue
+							}
+							cost, err := strconv.ParseFloat(dimension.PricePerUnit["USD"], 64)
+							if err != nil || cost == 0 {
+								// Dedicated hosts are billed per host, not per instance
+								continue
+							}
+							p.Prices[key.String()] = cost
+						}
+					}
+					return nil
+				})
+			})
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %v of offer file: %v", field, err)
+		}
+	}
+	if p.Version == "" {
+		return nil, fmt.Errorf("offer file has no version")
+	}
+	return p, nil
+}
+
+// decodeObject calls fn with each key of the JSON object read next from dec, fn must decode the value
+func decodeObject(dec *json.Decoder, fn func(key string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("expected an object key, found %v", t)
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// skipValue reads past the JSON value read next from dec a token at a time, so values as large
+// as the reserved terms of an offer file are never held in memory
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("expected %v, found %v", delim, t)
+	}
+	return nil
+}
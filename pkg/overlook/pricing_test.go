@@ -0,0 +1,161 @@
+package overlook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPriceListVersionsLeaveOutSpotPrices(t *testing.T) {
+	dir := t.TempDir()
+	if err := WritePriceList(dir, &PriceList{Version: "20240101", Prices: map[string]float64{}}); err != nil {
+		t.Fatal(err)
+	}
+	history := NewSpotPriceHistory()
+	history.Add("us-east-1a", "m5.large", "Linux/UNIX", SpotPricePoint{Timestamp: time.Now(), Price: 0.03})
+	if err := WriteSpotPriceHistory(dir, history); err != nil {
+		t.Fatal(err)
+	}
+	// Spot prices cached by earlier versions among the price lists
+	if err := ioutil.WriteFile(filepath.Join(dir, legacySpotPriceHistoryFileName), []byte(`{}`), 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := PriceListVersions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(versions) != "[20240101]" {
+		t.Errorf("expected only the price list, got %v", versions)
+	}
+	if err := SetCurrentPriceListVersion(dir, "spot"); err == nil {
+		t.Error("expected the spot prices not to be usable as a price list")
+	}
+	if _, err := ReadPriceList(dir, "spot"); err == nil {
+		t.Error("expected the spot prices not to be read as a price list")
+	}
+	if err := SetCurrentPriceListVersion(dir, "20240101"); err != nil {
+		t.Error(err)
+	}
+
+	// A file without a version is not a price list
+	if err := ioutil.WriteFile(filepath.Join(dir, "other.json"), []byte(`{"Prices": {}}`), 0660); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadPriceList(dir, "other"); err == nil {
+		t.Error("expected a price list without a version to be rejected")
+	}
+}
+
+func TestSpotPriceHistoryMovesFromLegacyLocation(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"us-east-1a/m5.large/Linux/UNIX": [{"Timestamp": "2021-03-01T00:00:00Z", "Price": 0.03}]}`
+	if err := ioutil.WriteFile(filepath.Join(dir, legacySpotPriceHistoryFileName), []byte(legacy), 0660); err != nil {
+		t.Fatal(err)
+	}
+	history, err := ReadSpotPriceHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := history.PriceAt("us-east-1a", "m5.large", "Linux/UNIX", time.Date(2021, 3, 1, 1, 0, 0, 0, time.UTC)); !ok {
+		t.Fatalf("expected the legacy spot prices, got %v", history.Prices)
+	}
+	if err := WriteSpotPriceHistory(dir, history); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, legacySpotPriceHistoryFileName)); !os.IsNotExist(err) {
+		t.Errorf("expected the legacy spot prices to be removed once written, got %v", err)
+	}
+	history, err = ReadSpotPriceHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Prices) != 1 {
+		t.Errorf("expected the spot prices to be kept, got %v", history.Prices)
+	}
+}
+
+// testOfferFile is a cut down EC2 offer file, products without a usable on-demand rate are priced
+// far off their neighbours so they stand out if imported
+const testOfferFile = `{
+  "formatVersion": "v1.0",
+  "disclaimer": "This pricing list is for informational purposes only.",
+  "offerCode": "AmazonEC2",
+  "version": "20210301000000",
+  "publicationDate": "2021-03-01T00:00:00Z",
+  "products": {
+    "LINUX": {"sku": "LINUX", "productFamily": "Compute Instance", "attributes": {"regionCode": "us-east-1",
+      "instanceType": "m5.large", "operatingSystem": "Linux", "tenancy": "Shared", "preInstalledSw": "NA",
+      "licenseModel": "No License required", "capacitystatus": "Used"}},
+    "RESERVATION": {"sku": "RESERVATION", "productFamily": "Compute Instance", "attributes": {"regionCode": "us-east-1",
+      "instanceType": "m5.large", "operatingSystem": "Linux", "tenancy": "Shared", "preInstalledSw": "NA",
+      "licenseModel": "No License required", "capacitystatus": "UnusedCapacityReservation"}},
+    "WINDOWS": {"sku": "WINDOWS", "productFamily": "Compute Instance", "attributes": {"regionCode": "us-east-1",
+      "instanceType": "m5.large", "operatingSystem": "Windows", "tenancy": "Shared", "preInstalledSw": "NA",
+      "licenseModel": "No License required", "capacitystatus": "Used"}},
+    "BYOL": {"sku": "BYOL", "productFamily": "Compute Instance", "attributes": {"regionCode": "us-east-1",
+      "instanceType": "m5.large", "operatingSystem": "Windows", "tenancy": "Shared", "preInstalledSw": "NA",
+      "licenseModel": "Bring your own license", "capacitystatus": "Used"}},
+    "SQL": {"sku": "SQL", "productFamily": "Compute Instance", "attributes": {"regionCode": "us-east-1",
+      "instanceType": "m5.large", "operatingSystem": "Windows", "tenancy": "Shared", "preInstalledSw": "SQL Std",
+      "licenseModel": "No License required", "capacitystatus": "Used"}},
+    "LOCATION": {"sku": "LOCATION", "productFamily": "Compute Instance", "attributes": {"location": "EU (Ireland)",
+      "instanceType": "m5.large", "operatingSystem": "Linux", "tenancy": "Shared", "preInstalledSw": "NA"}},
+    "DEDICATED": {"sku": "DEDICATED", "productFamily": "Compute Instance", "attributes": {"regionCode": "us-east-1",
+      "instanceType": "m5.large", "operatingSystem": "Linux", "tenancy": "Dedicated", "preInstalledSw": "NA",
+      "licenseModel": "No License required", "capacitystatus": "Used"}},
+    "HOST": {"sku": "HOST", "productFamily": "Compute Instance", "attributes": {"regionCode": "us-east-1",
+      "instanceType": "m5.large", "operatingSystem": "Linux", "tenancy": "Host", "preInstalledSw": "NA",
+      "licenseModel": "No License required", "capacitystatus": "Used"}},
+    "STORAGE": {"sku": "STORAGE", "productFamily": "Storage", "attributes": {"regionCode": "us-east-1", "volumeType": "gp2"}}
+  },
+  "terms": {
+    "Reserved": {
+      "LINUX": {"LINUX.RESERVED": {"priceDimensions": {"LINUX.RESERVED.UPFRONT": {"unit": "Quantity",
+        "pricePerUnit": {"USD": "500"}}, "LINUX.RESERVED.HOURLY": {"unit": "Hrs", "pricePerUnit": {"USD": "9.0"}}},
+        "termAttributes": {"LeaseContractLength": "1yr", "PurchaseOption": ["Partial Upfront"]}}}
+    },
+    "OnDemand": {
+      "LINUX": {"LINUX.OD": {"priceDimensions": {"LINUX.OD.HRS": {"unit": "Hrs", "pricePerUnit": {"USD": "0.0960000000"}}}}},
+      "RESERVATION": {"RESERVATION.OD": {"priceDimensions": {"RESERVATION.OD.HRS": {"unit": "Hrs", "pricePerUnit": {"USD": "9.0"}}}}},
+      "WINDOWS": {"WINDOWS.OD": {"priceDimensions": {"WINDOWS.OD.HRS": {"unit": "Hrs", "pricePerUnit": {"USD": "0.1880000000"}}}}},
+      "BYOL": {"BYOL.OD": {"priceDimensions": {"BYOL.OD.HRS": {"unit": "Hrs", "pricePerUnit": {"USD": "9.0"}}}}},
+      "SQL": {"SQL.OD": {"priceDimensions": {"SQL.OD.HRS": {"unit": "Hrs", "pricePerUnit": {"USD": "0.5680000000"}}}}},
+      "LOCATION": {"LOCATION.OD": {"priceDimensions": {"LOCATION.OD.HRS": {"unit": "Hrs", "pricePerUnit": {"USD": "0.1070000000"}}}}},
+      "DEDICATED": {"DEDICATED.OD": {"priceDimensions": {"DEDICATED.OD.HRS": {"unit": "Hrs", "pricePerUnit": {"USD": "0.1060000000"}}}}},
+      "HOST": {"HOST.OD": {"priceDimensions": {"HOST.OD.HRS": {"unit": "Hrs", "pricePerUnit": {"USD": "0.0000000000"}}}}},
+      "STORAGE": {"STORAGE.OD": {"priceDimensions": {"STORAGE.OD.GB": {"unit": "GB-Mo", "pricePerUnit": {"USD": "0.10"}}}}}
+    }
+  },
+  "attributesList": {"tenancy": ["Shared", "Dedicated", "Host"]}
+}`
+
+func TestImportOfferFile(t *testing.T) {
+	p, err := ImportOfferFile(strings.NewReader(testOfferFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != "20210301000000" || p.PublicationDate != "2021-03-01T00:00:00Z" {
+		t.Errorf("expected the version and publication date of the offer file, got %q and %q", p.Version, p.PublicationDate)
+	}
+	expected := map[string]float64{
+		PriceKey{"us-east-1", "m5.large", "Linux", "Shared"}.String():                0.096,
+		PriceKey{"us-east-1", "m5.large", "Windows", "Shared"}.String():              0.188,
+		PriceKey{"us-east-1", "m5.large", "Windows with SQL Std", "Shared"}.String(): 0.568,
+		PriceKey{"eu-west-1", "m5.large", "Linux", "Shared"}.String():                0.107,
+		PriceKey{"us-east-1", "m5.large", "Linux", "Dedicated"}.String():             0.106,
+	}
+	if fmt.Sprint(p.Prices) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, p.Prices)
+	}
+}
+
+func TestImportOfferFileWithoutVersion(t *testing.T) {
+	if _, err := ImportOfferFile(strings.NewReader(`{"products": {}, "terms": {"Reserved": {"a": [1, {"b": []}]}}}`)); err == nil {
+		t.Error("expected an offer file without a version to be rejected")
+	}
+}
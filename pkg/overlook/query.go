@@ -0,0 +1,111 @@
+package overlook
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// SnapshotFilter selects the snapshots of a range of days matching every field that is set
+type SnapshotFilter struct {
+	// From and To are the first and last day, a zero time leaves the range open
+	From         time.Time
+	To           time.Time
+	Account      string
+	Region       string
+	InstanceType string
+	State        string
+}
+
+// SnapshotQuerier is implemented by snapshot stores that select snapshots themselves
+type SnapshotQuerier interface {
+	// Query returns the snapshots matching filter, a BillingDailyEntry per day, most recent first
+	Query(filter SnapshotFilter) ([]BillingDailyEntry, error)
+}
+
+// QuerySnapshots returns the snapshots of store matching filter, a BillingDailyEntry per day with
+// a matching snapshot, most recent first
+func QuerySnapshots(store SnapshotStore, filter SnapshotFilter) ([]BillingDailyEntry, error) {
+	if q, ok := store.(SnapshotQuerier); ok {
+		return q.Query(filter)
+	}
+	days, err := store.Days()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]BillingDailyEntry, 0)
+	for _, day := range days {
+		if !filter.includesDay(day) {
+			continue
+		}
+		dailyEntry, err := store.Read(day)
+		if _, ok := err.(*CorruptSnapshotError); ok {
+			// One corrupted day does not keep the other days from being reported
+			log.Errorln("Skipping", day, err)
+			fmt.Println("WARNING: Skipping", day, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if dailyEntry = filter.apply(dailyEntry); len(dailyEntry) > 0 {
+			entries = append(entries, dailyEntry)
+		}
+	}
+	return entries, nil
+}
+
+// includesDay reports whether the day named day is within the range of the filter, days of either
+// layout are compared by their date
+func (f SnapshotFilter) includesDay(day string) bool {
+	t, err := parseDay(day)
+	if err != nil {
+		return false
+	}
+	date := dateOf(t)
+	if !f.From.IsZero() && date.Before(dateOf(f.From)) {
+		return false
+	}
+	if !f.To.IsZero() && date.After(dateOf(f.To)) {
+		return false
+	}
+	return true
+}
+
+// matches reports whether a snapshot taken in region matches the filter, snapshots taken before the
+// state was recorded are running
+func (f SnapshotFilter) matches(region string, b BillingSnapshot) bool {
+	return (f.Account == "" || b.AccountID == f.Account) &&
+		(f.Region == "" || region == f.Region) &&
+		(f.InstanceType == "" || b.InstanceType == f.InstanceType) &&
+		(f.State == "" || b.InstanceState() == f.State)
+}
+
+// apply returns the samples of dailyEntry with a snapshot matching the filter, keeping only those snapshots
+func (f SnapshotFilter) apply(dailyEntry BillingDailyEntry) BillingDailyEntry {
+	filtered := make(BillingDailyEntry)
+	for date, dayEntry := range dailyEntry {
+		samples := make(BillingTimeEntry)
+		for key, sampleEntry := range dayEntry {
+			sample := BillingSampleEntry{Regions: make(BillingRegionEntry), FailedRegions: sampleEntry.FailedRegions, Interval: sampleEntry.Interval}
+			for region, regionEntry := range sampleEntry.Regions {
+				for id, b := range regionEntry {
+					if !f.matches(region, b) {
+						continue
+					}
+					if _, ok := sample.Regions[region]; !ok {
+						sample.Regions[region] = make(BillingInstancesEntry)
+					}
+					sample.Regions[region][id] = b
+				}
+			}
+			if len(sample.Regions) > 0 {
+				samples[key] = sample
+			}
+		}
+		if len(samples) > 0 {
+			filtered[date] = samples
+		}
+	}
+	return filtered
+}
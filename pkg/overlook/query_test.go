@@ -0,0 +1,39 @@
+package overlook
+
+import "testing"
+
+func TestSnapshotFilterStateWithoutRecordedState(t *testing.T) {
+	dailyEntry := testDailyEntry("2021-03-01", "i-1")
+	for _, sample := range dailyEntry["2021-03-01"] {
+		// Snapshots taken before the state was recorded
+		b := sample.Regions["us-east-1"]["i-1"]
+		b.State = ""
+		sample.Regions["us-east-1"]["i-1"] = b
+		sample.Regions["us-east-1"]["i-2"] = BillingSnapshot{ID: "i-2", InstanceType: "m5.large", Region: "us-east-1", State: InstanceStateStopped}
+	}
+
+	sqliteStore := openTestSQLiteStore(t)
+	fileStore := NewFileSnapshotStore(t.TempDir())
+	for _, store := range []SnapshotStore{sqliteStore, fileStore} {
+		if err := store.Write("2021-03-01", dailyEntry); err != nil {
+			t.Fatal(err)
+		}
+		for state, expected := range map[string]string{InstanceStateRunning: "i-1", InstanceStateStopped: "i-2"} {
+			entries, err := QuerySnapshots(store, SnapshotFilter{State: state})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0)
+			for _, dailyEntry := range entries {
+				for _, sample := range dailyEntry["2021-03-01"] {
+					for id := range sample.Regions["us-east-1"] {
+						ids = append(ids, id)
+					}
+				}
+			}
+			if len(ids) != 1 || ids[0] != expected {
+				t.Errorf("%T: expected %s to be %s, got %v", store, expected, state, ids)
+			}
+		}
+	}
+}
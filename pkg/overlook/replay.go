@@ -0,0 +1,296 @@
+package overlook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// The layout of a recording is
+//	$DIR/recording.json                              {"$RecordingInfo"}
+//	$DIR/$ACCOUNT_ID/DescribeRegions.json
+//	$DIR/$ACCOUNT_ID/$REGION/DescribeInstances.json  [{"$PAGE_1"}, {"$PAGE_2"}]
+//	$DIR/$ACCOUNT_ID/$REGION/DescribeVolumes.json
+//	...
+// Each file holds the raw responses of an EC2 operation, one per page.
+
+const recordingInfoFileName = "recording.json"
+
+// RecordingInfo describes when a recording was made
+type RecordingInfo struct {
+	RecordedAt time.Time
+}
+
+// RecordingDir returns the directory holding the responses of an account and region,
+// an empty region is used for calls which are not made against a specific region
+func RecordingDir(baseDir string, accountID string, region string) string {
+	return filepath.Join(baseDir, accountID, region)
+}
+
+// WriteRecordingInfo stores when the recording in baseDir was made
+func WriteRecordingInfo(baseDir string, recordedAt time.Time) error {
+	return writeRecording(baseDir, recordingInfoFileName, RecordingInfo{RecordedAt: recordedAt})
+}
+
+// ReadRecordingInfo returns when the recording in baseDir was made
+func ReadRecordingInfo(baseDir string) (RecordingInfo, error) {
+	var info RecordingInfo
+	err := readRecording(baseDir, recordingInfoFileName, &info)
+	return info, err
+}
+
+// RecordedAccounts returns the IDs of the accounts with responses in baseDir
+func RecordedAccounts(baseDir string) ([]string, error) {
+	return subDirs(baseDir)
+}
+
+// RecordedRegions returns the regions of an account with responses in baseDir
+func RecordedRegions(baseDir string, accountID string) ([]string, error) {
+	return subDirs(RecordingDir(baseDir, accountID, ""))
+}
+
+func subDirs(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, f := range files {
+		if f.IsDir() {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+func writeRecording(dir string, name string, v interface{}) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, operationFileName(name)), data, 0660)
+}
+
+func readRecording(dir string, name string, v interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, operationFileName(name)))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func operationFileName(name string) string {
+	if filepath.Ext(name) == ".json" {
+		return name
+	}
+	return name + ".json"
+}
+
+// RecordingEC2 saves the raw responses of the EC2 calls made while sampling to Dir
+type RecordingEC2 struct {
+	ec2iface.EC2API
+	Dir string
+}
+
+// NewRecordingEC2 returns svc wrapped so its responses are saved to dir
+func NewRecordingEC2(svc ec2iface.EC2API, dir string) *RecordingEC2 {
+	return &RecordingEC2{EC2API: svc, Dir: dir}
+}
+
+// DescribeRegionsWithContext calls DescribeRegions and records the response
+func (r *RecordingEC2) DescribeRegionsWithContext(ctx aws.Context, input *ec2.DescribeRegionsInput, opts ...request.Option) (*ec2.DescribeRegionsOutput, error) {
+	out, err := r.EC2API.DescribeRegionsWithContext(ctx, input, opts...)
+	if err != nil {
+		return out, err
+	}
+	return out, writeRecording(r.Dir, "DescribeRegions", []*ec2.DescribeRegionsOutput{out})
+}
+
+// DescribeInstancesPagesWithContext calls DescribeInstances and records every page
+func (r *RecordingEC2) DescribeInstancesPagesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool, opts ...request.Option) error {
+	pages := make([]*ec2.DescribeInstancesOutput, 0)
+	err := r.EC2API.DescribeInstancesPagesWithContext(ctx, input, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		pages = append(pages, page)
+		return fn(page, lastPage)
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	return writeRecording(r.Dir, "DescribeInstances", pages)
+}
+
+// DescribeVolumesPagesWithContext calls DescribeVolumes and records every page
+func (r *RecordingEC2) DescribeVolumesPagesWithContext(ctx aws.Context, input *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool, opts ...request.Option) error {
+	pages := make([]*ec2.DescribeVolumesOutput, 0)
+	err := r.EC2API.DescribeVolumesPagesWithContext(ctx, input, func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+		pages = append(pages, page)
+		return fn(page, lastPage)
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	return writeRecording(r.Dir, "DescribeVolumes", pages)
+}
+
+// DescribeAddressesWithContext calls DescribeAddresses and records the response
+func (r *RecordingEC2) DescribeAddressesWithContext(ctx aws.Context, input *ec2.DescribeAddressesInput, opts ...request.Option) (*ec2.DescribeAddressesOutput, error) {
+	out, err := r.EC2API.DescribeAddressesWithContext(ctx, input, opts...)
+	if err != nil {
+		return out, err
+	}
+	return out, writeRecording(r.Dir, "DescribeAddresses", []*ec2.DescribeAddressesOutput{out})
+}
+
+// DescribeNatGatewaysWithContext calls DescribeNatGateways and records the response, pages are
+// requested by the caller so every page is appended to the recording
+func (r *RecordingEC2) DescribeNatGatewaysWithContext(ctx aws.Context, input *ec2.DescribeNatGatewaysInput, opts ...request.Option) (*ec2.DescribeNatGatewaysOutput, error) {
+	out, err := r.EC2API.DescribeNatGatewaysWithContext(ctx, input, opts...)
+	if err != nil {
+		return out, err
+	}
+	pages := make([]*ec2.DescribeNatGatewaysOutput, 0)
+	if input.NextToken != nil {
+		// Continue the recording of the first page
+		if err := readRecording(r.Dir, "DescribeNatGateways", &pages); err != nil {
+			return out, err
+		}
+	}
+	pages = append(pages, out)
+	return out, writeRecording(r.Dir, "DescribeNatGateways", pages)
+}
+
+// DescribeSpotPriceHistoryPagesWithContext calls DescribeSpotPriceHistory and records every page
+func (r *RecordingEC2) DescribeSpotPriceHistoryPagesWithContext(ctx aws.Context, input *ec2.DescribeSpotPriceHistoryInput, fn func(*ec2.DescribeSpotPriceHistoryOutput, bool) bool, opts ...request.Option) error {
+	pages := make([]*ec2.DescribeSpotPriceHistoryOutput, 0)
+	err := r.EC2API.DescribeSpotPriceHistoryPagesWithContext(ctx, input, func(page *ec2.DescribeSpotPriceHistoryOutput, lastPage bool) bool {
+		pages = append(pages, page)
+		return fn(page, lastPage)
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	return writeRecording(r.Dir, "DescribeSpotPriceHistory", pages)
+}
+
+// ErrCodeNotRecorded is the error code of EC2 calls which are never recorded, when replayed
+const ErrCodeNotRecorded = "NotRecorded"
+
+// ReplayEC2 answers the EC2 calls made while sampling from the responses recorded in Dir.
+// Calls which are never recorded fail with ErrCodeNotRecorded.
+type ReplayEC2 struct {
+	ec2iface.EC2API
+	Dir string
+}
+
+// NewReplayEC2 returns an EC2 API which replays the responses recorded in dir
+func NewReplayEC2(dir string) *ReplayEC2 {
+	return &ReplayEC2{EC2API: newNotRecordedEC2(), Dir: dir}
+}
+
+// newNotRecordedEC2 returns an EC2 client which fails every call before it is sent
+func newNotRecordedEC2() ec2iface.EC2API {
+	sess := &session.Session{Config: aws.NewConfig().WithRegion("replay").WithEndpoint("replay.invalid")}
+	svc := ec2.New(sess)
+	svc.Handlers.Clear()
+	svc.Handlers.Validate.PushBack(func(r *request.Request) {
+		r.Error = awserr.New(ErrCodeNotRecorded, r.Operation.Name+" is not recorded, it can not be replayed", nil)
+	})
+	return svc
+}
+
+// DescribeRegionsWithContext returns the recorded DescribeRegions response
+func (r *ReplayEC2) DescribeRegionsWithContext(ctx aws.Context, input *ec2.DescribeRegionsInput, opts ...request.Option) (*ec2.DescribeRegionsOutput, error) {
+	var pages []*ec2.DescribeRegionsOutput
+	if err := readRecording(r.Dir, "DescribeRegions", &pages); err != nil {
+		return nil, err
+	}
+	out := &ec2.DescribeRegionsOutput{}
+	for _, p := range pages {
+		out.Regions = append(out.Regions, p.Regions...)
+	}
+	return out, nil
+}
+
+// DescribeInstancesPagesWithContext replays the recorded DescribeInstances pages
+func (r *ReplayEC2) DescribeInstancesPagesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool, opts ...request.Option) error {
+	var pages []*ec2.DescribeInstancesOutput
+	if err := readRecording(r.Dir, "DescribeInstances", &pages); err != nil {
+		return err
+	}
+	for i, p := range pages {
+		if !fn(p, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+// DescribeVolumesPagesWithContext replays the recorded DescribeVolumes pages
+func (r *ReplayEC2) DescribeVolumesPagesWithContext(ctx aws.Context, input *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool, opts ...request.Option) error {
+	var pages []*ec2.DescribeVolumesOutput
+	if err := readRecording(r.Dir, "DescribeVolumes", &pages); err != nil {
+		return err
+	}
+	for i, p := range pages {
+		if !fn(p, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+// DescribeAddressesWithContext returns the recorded DescribeAddresses response
+func (r *ReplayEC2) DescribeAddressesWithContext(ctx aws.Context, input *ec2.DescribeAddressesInput, opts ...request.Option) (*ec2.DescribeAddressesOutput, error) {
+	var pages []*ec2.DescribeAddressesOutput
+	if err := readRecording(r.Dir, "DescribeAddresses", &pages); err != nil {
+		return nil, err
+	}
+	out := &ec2.DescribeAddressesOutput{}
+	for _, p := range pages {
+		out.Addresses = append(out.Addresses, p.Addresses...)
+	}
+	return out, nil
+}
+
+// DescribeNatGatewaysWithContext returns all recorded NAT gateways as a single page
+func (r *ReplayEC2) DescribeNatGatewaysWithContext(ctx aws.Context, input *ec2.DescribeNatGatewaysInput, opts ...request.Option) (*ec2.DescribeNatGatewaysOutput, error) {
+	var pages []*ec2.DescribeNatGatewaysOutput
+	if err := readRecording(r.Dir, "DescribeNatGateways", &pages); err != nil {
+		return nil, err
+	}
+	out := &ec2.DescribeNatGatewaysOutput{}
+	for _, p := range pages {
+		out.NatGateways = append(out.NatGateways, p.NatGateways...)
+	}
+	return out, nil
+}
+
+// DescribeSpotPriceHistoryPagesWithContext replays the recorded DescribeSpotPriceHistory pages,
+// regions without spot instances have none
+func (r *ReplayEC2) DescribeSpotPriceHistoryPagesWithContext(ctx aws.Context, input *ec2.DescribeSpotPriceHistoryInput, fn func(*ec2.DescribeSpotPriceHistoryOutput, bool) bool, opts ...request.Option) error {
+	var pages []*ec2.DescribeSpotPriceHistoryOutput
+	if err := readRecording(r.Dir, "DescribeSpotPriceHistory", &pages); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for i, p := range pages {
+		if !fn(p, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
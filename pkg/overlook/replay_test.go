@@ -0,0 +1,32 @@
+package overlook
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestReplayEC2CallNotRecorded(t *testing.T) {
+	replay := NewReplayEC2(t.TempDir())
+
+	_, err := replay.DescribeVpcsWithContext(aws.BackgroundContext(), &ec2.DescribeVpcsInput{})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != ErrCodeNotRecorded {
+		t.Errorf("expected a %s error, got %v", ErrCodeNotRecorded, err)
+	}
+	pages := 0
+	err = replay.DescribeSubnetsPages(&ec2.DescribeSubnetsInput{}, func(*ec2.DescribeSubnetsOutput, bool) bool {
+		pages++
+		return true
+	})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != ErrCodeNotRecorded || pages != 0 {
+		t.Errorf("expected a %s error without pages, got %v after %d pages", ErrCodeNotRecorded, err, pages)
+	}
+
+	// Calls which are recorded still fail on a missing recording rather than as not recorded
+	_, err = replay.DescribeAddressesWithContext(aws.BackgroundContext(), &ec2.DescribeAddressesInput{})
+	if aerr, ok := err.(awserr.Error); err == nil || (ok && aerr.Code() == ErrCodeNotRecorded) {
+		t.Errorf("expected the missing recording, got %v", err)
+	}
+}
@@ -3,18 +3,30 @@ package overlook
 import (
 	"errors"
 	log "github.com/sirupsen/logrus"
+	"time"
 )
 
 // NewReportDaily returns a new ReportDaily
 func NewReportDaily() ReportDaily {
 	var report = ReportDaily{}
 	report.Regions = make(map[string]ReportByRegion)
+	report.Accounts = make(map[string]ReportByAccount)
+	return report
+}
+
+// NewReportByAccount returns a new ReportByAccount
+func NewReportByAccount() ReportByAccount {
+	var report ReportByAccount
+	report.Regions = make(map[string]ReportByRegion)
 	return report
 }
 
 func NewReportByRegion() ReportByRegion {
 	var report ReportByRegion
 	report.InstanceTypes = make(map[string]ReportInstanceType)
+	report.VolumeTypes = make(map[string]ReportVolumeType)
+	report.NetworkTypes = make(map[string]ReportNetworkType)
+	report.DBInstanceClasses = make(map[string]ReportDBInstanceClass)
 	return report
 }
 
@@ -26,73 +38,370 @@ func PrintReport(r ReportDaily) {
 func GetReport(dailyEntry BillingDailyEntry) ReportDaily {
 	//
 	// We will walk through a report of usage which is focused on days usage of ec2..
-	// The usage report is organized by hour, showing what instances we've seen in that hour
-	// We want to consolidate the info by region and instanceType
+	// The usage report is organized by sample, showing what instances we've seen in that sample
+	// Each sample stands for the hours until the next one, we want to consolidate the info by
+	// region and instanceType, and again by account
 	//
 	for date, dayEntry := range dailyEntry {
 		// For each day we create a new report, we structured the JSON to only contain 1 day in an entry
 		var report = NewReportDaily()
 		report.Date = date
-		for _, hourEntry := range dayEntry {
-			for region, regionEntry := range hourEntry {
-				var ok bool
-				var reportByRegion ReportByRegion
-				reportByRegion, ok = report.Regions[region]
-				if !ok {
-					reportByRegion = NewReportByRegion()
-					reportByRegion.Region = region
-				}
-
+		coverage := newReservationCoverage(date)
+		unpriced := make(map[string]bool)
+		priceListVersions := make(map[string]bool)
+		for _, sample := range timedSamples(date, dayEntry) {
+			if len(sample.Sample.FailedRegions) > 0 {
+				report.IncompleteSamples = append(report.IncompleteSamples, ReportIncompleteSample{Time: sample.Time, FailedRegions: sample.Sample.FailedRegions})
+			}
+			at := sample.Time
+			hours := sample.Hours
+			usage := make([]instanceUsage, 0)
+			for region, regionEntry := range sample.Sample.Regions {
 				for _, instanceEntry := range regionEntry {
-					instType := instanceEntry.InstanceType
-					reportInst, ok := reportByRegion.InstanceTypes[instType]
-					if !ok {
-						reportInst = ReportInstanceType{}
-						reportInst.InstanceType = instType
-						reportInst.Hours = 0
-						reportInst.UniqueInstances = make(map[string]bool)
+					addToRegionReport(report.Regions, region, at, hours, instanceEntry)
+					if instanceEntry.IsInstance() && instanceEntry.PriceListVersion != "" {
+						priceListVersions[instanceEntry.PriceListVersion] = true
 					}
-					instanceCost, err := GetCostPerHour(reportInst.InstanceType)
-					if err != nil {
-						panic(err)
+					if instanceEntry.IsInstance() && instanceEntry.IsRunning() {
+						if u, err := newInstanceUsage(region, at, instanceEntry); err == nil {
+							usage = append(usage, u)
+						} else {
+							unpriced[instanceEntry.PriceKey().String()] = true
+						}
 					}
-					reportInst.Hours = reportInst.Hours + 1
-					reportInst.Cost = float64(reportInst.Hours) * instanceCost
-					reportInst.UniqueInstances[instanceEntry.ID] = true
-					reportByRegion.InstanceTypes[instType] = reportInst
+					if instanceEntry.PreviousState != "" {
+						report.Transitions = append(report.Transitions, ReportTransition{
+							Time:         at,
+							AccountID:    instanceEntry.AccountID,
+							Region:       region,
+							ID:           instanceEntry.ID,
+							InstanceType: instanceEntry.InstanceType,
+							From:         instanceEntry.PreviousState,
+							To:           instanceEntry.InstanceState(),
+						})
+					}
+
+					account := instanceEntry.AccountID
+					if account == "" {
+						account = UnknownAccount
+					}
+					reportByAccount, ok := report.Accounts[account]
+					if !ok {
+						reportByAccount = NewReportByAccount()
+						reportByAccount.Account = account
+					}
+					addToRegionReport(reportByAccount.Regions, region, at, hours, instanceEntry)
+					report.Accounts[account] = reportByAccount
 				}
-				report.Regions[region] = reportByRegion
 			}
+			coverage.addSample(usage, hours)
 		}
 		// Calculate cost per region
 		for region, reportByRegion := range report.Regions {
-			reportByRegion.Cost = 0
-			for _, reportInstType := range reportByRegion.InstanceTypes {
-				reportByRegion.Cost = reportByRegion.Cost + reportInstType.Cost
-			}
+			reportByRegion.calculateCost()
 			report.Regions[region] = reportByRegion
 		}
+		// Calculate cost per account, the effective cost of other resources than instances is
+		// discounted per account while instances are discounted along with reservations
+		var otherEffectiveCost float64
+		for account, reportByAccount := range report.Accounts {
+			reportByAccount.Cost = 0
+			reportByAccount.EffectiveCost = 0
+			var instanceCost float64
+			for region, reportByRegion := range reportByAccount.Regions {
+				reportByRegion.calculateCost()
+				reportByAccount.Regions[region] = reportByRegion
+				reportByAccount.Cost = reportByAccount.Cost + reportByRegion.Cost
+				reportByAccount.EffectiveCost = reportByAccount.EffectiveCost + reportByRegion.EffectiveCost
+				instanceCost = instanceCost + reportByRegion.InstanceCost
+			}
+			discount := 1 - accountDiscount(account)/100
+			reportByAccount.EffectiveCost = reportByAccount.EffectiveCost * discount
+			otherEffectiveCost = otherEffectiveCost + (reportByAccount.Cost-instanceCost)*discount
+			report.Accounts[account] = reportByAccount
+		}
 		// Calculate total cost
-		report.Cost = 0
 		for _, reportByRegion := range report.Regions {
-			for _, reportInstType := range reportByRegion.InstanceTypes {
-				report.Cost = report.Cost + reportInstType.Cost
+			report.InstanceCost = report.InstanceCost + reportByRegion.InstanceCost
+			report.VolumeCost = report.VolumeCost + reportByRegion.VolumeCost
+			for _, reportVolType := range reportByRegion.VolumeTypes {
+				report.UnattachedVolumeCost = report.UnattachedVolumeCost + reportVolType.UnattachedCost
+			}
+			report.NetworkCost = report.NetworkCost + reportByRegion.NetworkCost
+			for _, reportNetType := range reportByRegion.NetworkTypes {
+				switch reportNetType.ResourceType {
+				case ResourceTypeElasticIP:
+					report.ElasticIPCost = report.ElasticIPCost + reportNetType.Cost
+				case ResourceTypeNatGateway:
+					report.NatGatewayCost = report.NatGatewayCost + reportNetType.Cost
+				case ResourceTypeLoadBalancer:
+					report.LoadBalancerCost = report.LoadBalancerCost + reportNetType.Cost
+				}
+				report.IdleNetworkCost = report.IdleNetworkCost + reportNetType.IdleCost
 			}
 		}
+		for _, reportByRegion := range report.Regions {
+			report.DBCost = report.DBCost + reportByRegion.DBCost
+			report.UnknownCostHours = report.UnknownCostHours + reportByRegion.UnknownCostHours
+			reportByRegion.addUnpricedTypes(unpriced)
+		}
+		report.UnpricedInstanceTypes = sortedKeys(unpriced)
+		report.PricingMode = reportPricingMode
+		if reportPricingMode == PricingModeCurrent {
+			report.PriceListVersions = []string{ActivePriceListVersion()}
+		} else {
+			report.PriceListVersions = sortedKeys(priceListVersions)
+		}
+		report.Cost = report.InstanceCost + report.VolumeCost + report.NetworkCost + report.DBCost
+		coverage.apply(&report)
+		report.EffectiveCost = otherEffectiveCost + report.EffectiveInstanceCost
+		report.Currency = DefaultCurrency
+		if activePricingConfig.Currency != DefaultCurrency {
+			report.Currency = activePricingConfig.Currency
+			report.scaleCosts(activePricingConfig.ExchangeRate)
+		}
 		return report
 	}
 	// This should never happen
 	panic(errors.New("We should never reach here, problem parsing date in billing data"))
-	return ReportDaily{}
+}
+
+// snapshotCostPerHour returns the rate an instance was billed at in the sample taken at, following the
+// report pricing mode
+func snapshotCostPerHour(at time.Time, instanceEntry BillingSnapshot) (float64, error) {
+	if reportPricingMode == PricingModeRecorded && instanceEntry.CostPerHour > 0 {
+		return instanceEntry.CostPerHour, nil
+	}
+	cost, _, err := currentSnapshotCostPerHour(at, instanceEntry)
+	return cost, err
+}
+
+// currentSnapshotCostPerHour returns the rate of an instance from the active price list, spot instances
+// are billed the spot price at the time, or the price recorded in the snapshot when it is not known.
+// The source of the rate is returned along with it.
+func currentSnapshotCostPerHour(at time.Time, instanceEntry BillingSnapshot) (float64, string, error) {
+	if instanceEntry.IsSpot() {
+		cost, err := GetSpotCostPerHour(instanceEntry.PriceKey(), instanceEntry.AvailabilityZone, at)
+		if err == nil {
+			return cost, PriceSourceSpot, nil
+		}
+		if instanceEntry.CostPerHour > 0 {
+			return instanceEntry.CostPerHour, PriceSourceRecorded, nil
+		}
+	}
+	return GetInstanceCostPerHour(instanceEntry.SnapshotProvider(), instanceEntry.PriceKey(), instanceEntry.AvailabilityZone)
+}
+
+// newInstanceUsage returns the rate of a running instance for applying reservations
+func newInstanceUsage(region string, at time.Time, instanceEntry BillingSnapshot) (instanceUsage, error) {
+	instanceCost, err := effectiveSnapshotCostPerHour(at, instanceEntry)
+	if err != nil {
+		return instanceUsage{}, err
+	}
+	key := instanceEntry.PriceKey()
+	key.Region = region
+	return instanceUsage{
+		Provider:     instanceEntry.SnapshotProvider(),
+		Account:      instanceEntry.AccountID,
+		Lifecycle:    instanceEntry.Lifecycle,
+		Key:          key,
+		OnDemandCost: instanceCost,
+	}, nil
+}
+
+// addToRegionReport accounts for the hours a sample stands for of a snapshot in the matching region of regions
+func addToRegionReport(regions map[string]ReportByRegion, region string, at time.Time, hours float64, entry BillingSnapshot) {
+	reportByRegion, ok := regions[region]
+	if !ok {
+		reportByRegion = NewReportByRegion()
+		reportByRegion.Region = region
+	}
+	switch {
+	case entry.IsVolume():
+		addVolumeToReport(reportByRegion, hours, entry)
+	case entry.IsNetwork():
+		addNetworkToReport(reportByRegion, hours, entry)
+	case entry.IsDBInstance():
+		addDBInstanceToReport(reportByRegion, hours, entry)
+	default:
+		addInstanceToReport(reportByRegion, at, hours, entry)
+	}
+	regions[region] = reportByRegion
+}
+
+// addInstanceToReport accounts for the hours of a sample of an instance in the region report
+func addInstanceToReport(reportByRegion ReportByRegion, at time.Time, hours float64, instanceEntry BillingSnapshot) {
+	instType := instanceEntry.InstanceType
+	reportInst, ok := reportByRegion.InstanceTypes[instType]
+	if !ok {
+		reportInst = ReportInstanceType{}
+		reportInst.InstanceType = instType
+		reportInst.Hours = 0
+		reportInst.UniqueInstances = make(map[string]bool)
+	}
+	reportInst.UniqueInstances[instanceEntry.Key()] = true
+	if !instanceEntry.IsRunning() {
+		// Only running instances are billed, storage of stopped instances is accounted for with volumes
+		reportInst.StoppedHours = reportInst.StoppedHours + hours
+		reportByRegion.InstanceTypes[instType] = reportInst
+		return
+	}
+	reportInst.Hours = reportInst.Hours + hours
+	effectiveCost, err := effectiveSnapshotCostPerHour(at, instanceEntry)
+	if err != nil {
+		reportInst.UnpricedHours = reportInst.UnpricedHours + hours
+		reportByRegion.InstanceTypes[instType] = reportInst
+		return
+	}
+	instanceCost, err := snapshotCostPerHour(at, instanceEntry)
+	if err != nil {
+		// Only an override prices the instance, it stands in for the list price
+		instanceCost = effectiveCost
+	}
+	reportInst.Cost = reportInst.Cost + instanceCost*hours
+	reportInst.EffectiveCost = reportInst.EffectiveCost + effectiveCost*hours
+	reportByRegion.InstanceTypes[instType] = reportInst
+}
+
+// addVolumeToReport accounts for the hours of a sample of an EBS volume in the region report
+func addVolumeToReport(reportByRegion ReportByRegion, hours float64, volumeEntry BillingSnapshot) {
+	volType := volumeEntry.VolumeType
+	reportVol, ok := reportByRegion.VolumeTypes[volType]
+	if !ok {
+		reportVol = ReportVolumeType{}
+		reportVol.VolumeType = volType
+		reportVol.UniqueVolumes = make(map[string]bool)
+	}
+	reportVol.UniqueVolumes[volumeEntry.Key()] = true
+	reportVol.Hours = reportVol.Hours + hours
+	reportVol.GBHours = reportVol.GBHours + float64(volumeEntry.SizeGB)*hours
+	volumeCost, err := GetVolumeCostPerHour(volumeEntry.Region, volType, volumeEntry.SizeGB, volumeEntry.Iops)
+	if err != nil {
+		reportVol.UnpricedHours = reportVol.UnpricedHours + hours
+		reportByRegion.VolumeTypes[volType] = reportVol
+		return
+	}
+	reportVol.Cost = reportVol.Cost + volumeCost*hours
+	if volumeEntry.AttachedTo == "" {
+		reportVol.UnattachedCost = reportVol.UnattachedCost + volumeCost*hours
+	}
+	reportByRegion.VolumeTypes[volType] = reportVol
+}
+
+// addUnpricedTypes adds the types of the resources other than instances that were billed without a
+// price in the region to unpriced, unpriced instances are added by their price key as they are sampled
+func (r ReportByRegion) addUnpricedTypes(unpriced map[string]bool) {
+	for volType, reportVolType := range r.VolumeTypes {
+		if reportVolType.UnpricedHours > 0 {
+			unpriced["EBS "+volType] = true
+		}
+	}
+	for category, reportNetType := range r.NetworkTypes {
+		if reportNetType.UnpricedHours > 0 {
+			unpriced[category] = true
+		}
+	}
+	for dbClass, reportDBClass := range r.DBInstanceClasses {
+		if reportDBClass.UnpricedHours > 0 {
+			unpriced["RDS "+dbClass] = true
+		}
+	}
+}
+
+// calculateCost sums the cost of every instance, volume, network type and DB instance class in the region
+func (r *ReportByRegion) calculateCost() {
+	r.InstanceCost = 0
+	r.UnknownCostHours = 0
+	for _, reportInstType := range r.InstanceTypes {
+		r.InstanceCost = r.InstanceCost + reportInstType.Cost
+		r.UnknownCostHours = r.UnknownCostHours + reportInstType.UnpricedHours
+	}
+	r.VolumeCost = 0
+	for _, reportVolType := range r.VolumeTypes {
+		r.VolumeCost = r.VolumeCost + reportVolType.Cost
+		r.UnknownCostHours = r.UnknownCostHours + reportVolType.UnpricedHours
+	}
+	r.NetworkCost = 0
+	for _, reportNetType := range r.NetworkTypes {
+		r.NetworkCost = r.NetworkCost + reportNetType.Cost
+		r.UnknownCostHours = r.UnknownCostHours + reportNetType.UnpricedHours
+	}
+	r.DBCost = 0
+	for _, reportDBClass := range r.DBInstanceClasses {
+		r.DBCost = r.DBCost + reportDBClass.Cost
+		r.UnknownCostHours = r.UnknownCostHours + reportDBClass.UnpricedHours
+	}
+	r.Cost = r.InstanceCost + r.VolumeCost + r.NetworkCost + r.DBCost
+	// Price overrides only apply to instances
+	r.EffectiveCost = r.Cost - r.InstanceCost
+	for _, reportInstType := range r.InstanceTypes {
+		r.EffectiveCost = r.EffectiveCost + reportInstType.EffectiveCost
+	}
+}
+
+// addNetworkToReport accounts for the hours of a sample of an Elastic IP, NAT gateway or load balancer in the region report
+func addNetworkToReport(reportByRegion ReportByRegion, hours float64, networkEntry BillingSnapshot) {
+	category := networkEntry.NetworkCategory()
+	reportNet, ok := reportByRegion.NetworkTypes[category]
+	if !ok {
+		reportNet = ReportNetworkType{}
+		reportNet.Category = category
+		reportNet.ResourceType = networkEntry.ResourceType
+		reportNet.UniqueResources = make(map[string]bool)
+	}
+	reportNet.UniqueResources[networkEntry.Key()] = true
+	reportNet.Hours = reportNet.Hours + hours
+	networkCost, err := GetNetworkCostPerHour(networkEntry.Region, networkEntry.ResourceType, networkEntry.LoadBalancerType)
+	if err != nil {
+		reportNet.UnpricedHours = reportNet.UnpricedHours + hours
+		reportByRegion.NetworkTypes[category] = reportNet
+		return
+	}
+	reportNet.Cost = reportNet.Cost + networkCost*hours
+	if networkEntry.Idle {
+		reportNet.IdleCost = reportNet.IdleCost + networkCost*hours
+	}
+	reportByRegion.NetworkTypes[category] = reportNet
+}
+
+// addDBInstanceToReport accounts for the hours of a sample of an RDS DB instance in the region report
+func addDBInstanceToReport(reportByRegion ReportByRegion, hours float64, dbEntry BillingSnapshot) {
+	dbClass := dbEntry.DBInstanceClass
+	reportDB, ok := reportByRegion.DBInstanceClasses[dbClass]
+	if !ok {
+		reportDB = ReportDBInstanceClass{}
+		reportDB.DBInstanceClass = dbClass
+		reportDB.UniqueInstances = make(map[string]bool)
+	}
+	reportDB.UniqueInstances[dbEntry.Key()] = true
+	if dbEntry.State == dbStatusStopped {
+		reportDB.StoppedHours = reportDB.StoppedHours + hours
+		storageCost, err := GetDBStorageCostPerHour(dbEntry.Region, dbEntry.MultiAZ, dbEntry.SizeGB)
+		if err != nil {
+			reportDB.UnpricedHours = reportDB.UnpricedHours + hours
+		}
+		reportDB.Cost = reportDB.Cost + storageCost*hours
+		reportByRegion.DBInstanceClasses[dbClass] = reportDB
+		return
+	}
+	reportDB.Hours = reportDB.Hours + hours
+	dbCost, err := GetDBCostPerHour(dbEntry.Region, dbClass, dbEntry.MultiAZ, dbEntry.SizeGB)
+	if err != nil {
+		reportDB.UnpricedHours = reportDB.UnpricedHours + hours
+		reportByRegion.DBInstanceClasses[dbClass] = reportDB
+		return
+	}
+	reportDB.Cost = reportDB.Cost + dbCost*hours
+	reportByRegion.DBInstanceClasses[dbClass] = reportDB
 }
 
 // PrintCalculateReport returns a summary of usage and costs for as given BillingDailyEntry
 func PrintCalculateReport(dailyEntry BillingDailyEntry) {
 	for date, dayEntry := range dailyEntry {
 		log.Infoln(date)
-		for hour, hourEntry := range dayEntry {
-			log.Infoln("\t", hour)
-			for region, regionEntry := range hourEntry {
+		for key, sample := range dayEntry {
+			log.Infoln("\t", key)
+			for region, regionEntry := range sample.Regions {
 				log.Infoln("\t\t", region)
 				for instanceID, instanceEntry := range regionEntry {
 					log.Infoln("\t\t\t", instanceID, ":", "up ", instanceEntry.HoursUp)
@@ -0,0 +1,107 @@
+package overlook
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testReport returns the report of a day with a single sample of the snapshots, standing for an hour
+func testReport(t *testing.T, snapshots ...BillingSnapshot) ReportDaily {
+	t.Helper()
+	instances := make(BillingInstancesEntry)
+	for _, b := range snapshots {
+		instances[b.ID] = b
+	}
+	at := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	return GetReport(BillingDailyEntry{"2021-03-01": BillingTimeEntry{
+		sampleKey(at): BillingSampleEntry{Regions: BillingRegionEntry{"us-east-1": instances}, Interval: time.Hour},
+	}})
+}
+
+func TestReportUnknownVolumeType(t *testing.T) {
+	report := testReport(t,
+		BillingSnapshot{ID: "vol-1", ResourceType: ResourceTypeVolume, VolumeType: "gp2", SizeGB: 100, Region: "us-east-1"},
+		BillingSnapshot{ID: "vol-2", ResourceType: ResourceTypeVolume, VolumeType: "unknown", SizeGB: 100, Region: "us-east-1"},
+	)
+	region := report.Regions["us-east-1"]
+	priced, unpriced := region.VolumeTypes["gp2"], region.VolumeTypes["unknown"]
+	if priced.Cost <= 0 || priced.UnpricedHours != 0 {
+		t.Errorf("expected gp2 to be priced, got %+v", priced)
+	}
+	if unpriced.Cost != 0 || unpriced.UnpricedHours != unpriced.Hours || unpriced.Hours <= 0 {
+		t.Errorf("expected the hours of the unknown volume type to be unpriced, got %+v", unpriced)
+	}
+	if report.VolumeCost != priced.Cost || report.UnknownCostHours != unpriced.Hours {
+		t.Errorf("expected only gp2 in the volume cost, got cost %.4f and %.2f unknown cost hours", report.VolumeCost, report.UnknownCostHours)
+	}
+	if !reflect.DeepEqual(report.UnpricedInstanceTypes, []string{"EBS unknown"}) {
+		t.Errorf("expected the unknown volume type to be listed, got %v", report.UnpricedInstanceTypes)
+	}
+}
+
+func TestReportUnknownNetworkCategory(t *testing.T) {
+	report := testReport(t,
+		BillingSnapshot{ID: "nat-1", ResourceType: ResourceTypeNatGateway, Region: "us-east-1"},
+		BillingSnapshot{ID: "lb-1", ResourceType: ResourceTypeLoadBalancer, LoadBalancerType: "unknown", Idle: true, Region: "us-east-1"},
+	)
+	region := report.Regions["us-east-1"]
+	priced, unpriced := region.NetworkTypes[ResourceTypeNatGateway], region.NetworkTypes[ResourceTypeLoadBalancer+"/unknown"]
+	if priced.Cost <= 0 || priced.UnpricedHours != 0 {
+		t.Errorf("expected the NAT gateway to be priced, got %+v", priced)
+	}
+	if unpriced.Cost != 0 || unpriced.IdleCost != 0 || unpriced.UnpricedHours != unpriced.Hours || unpriced.Hours <= 0 {
+		t.Errorf("expected the hours of the unknown load balancer type to be unpriced, got %+v", unpriced)
+	}
+	if report.NetworkCost != priced.Cost || report.UnknownCostHours != unpriced.Hours {
+		t.Errorf("expected only the NAT gateway in the network cost, got cost %.4f and %.2f unknown cost hours", report.NetworkCost, report.UnknownCostHours)
+	}
+	if !reflect.DeepEqual(report.UnpricedInstanceTypes, []string{ResourceTypeLoadBalancer + "/unknown"}) {
+		t.Errorf("expected the unknown load balancer type to be listed, got %v", report.UnpricedInstanceTypes)
+	}
+}
+
+func TestReportUnknownDBInstanceClass(t *testing.T) {
+	report := testReport(t,
+		BillingSnapshot{ID: "db-1", ResourceType: ResourceTypeDBInstance, DBInstanceClass: "db.t2.micro", SizeGB: 20, State: "available", Region: "us-east-1"},
+		BillingSnapshot{ID: "db-2", ResourceType: ResourceTypeDBInstance, DBInstanceClass: "db.unknown", SizeGB: 20, State: "available", Region: "us-east-1"},
+	)
+	region := report.Regions["us-east-1"]
+	priced, unpriced := region.DBInstanceClasses["db.t2.micro"], region.DBInstanceClasses["db.unknown"]
+	if priced.Cost <= 0 || priced.UnpricedHours != 0 {
+		t.Errorf("expected db.t2.micro to be priced, got %+v", priced)
+	}
+	if unpriced.Cost != 0 || unpriced.UnpricedHours != unpriced.Hours || unpriced.Hours <= 0 {
+		t.Errorf("expected the hours of the unknown DB instance class to be unpriced, got %+v", unpriced)
+	}
+	if report.DBCost != priced.Cost || report.UnknownCostHours != unpriced.Hours {
+		t.Errorf("expected only db.t2.micro in the DB cost, got cost %.4f and %.2f unknown cost hours", report.DBCost, report.UnknownCostHours)
+	}
+	if !reflect.DeepEqual(report.UnpricedInstanceTypes, []string{"RDS db.unknown"}) {
+		t.Errorf("expected the unknown DB instance class to be listed, got %v", report.UnpricedInstanceTypes)
+	}
+}
+
+func TestReportResourcesOutsideDefaultRegionUnpriced(t *testing.T) {
+	at := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	instances := BillingInstancesEntry{}
+	for _, b := range []BillingSnapshot{
+		{ID: "vol-1", ResourceType: ResourceTypeVolume, VolumeType: "gp2", SizeGB: 100},
+		{ID: "nat-1", ResourceType: ResourceTypeNatGateway},
+		{ID: "db-1", ResourceType: ResourceTypeDBInstance, DBInstanceClass: "db.t2.micro", State: "available"},
+		{ID: "db-2", ResourceType: ResourceTypeDBInstance, DBInstanceClass: "db.t2.micro", State: dbStatusStopped, SizeGB: 20},
+	} {
+		b.Region = "eu-west-1"
+		instances[b.ID] = b
+	}
+	report := GetReport(BillingDailyEntry{"2021-03-01": BillingTimeEntry{
+		sampleKey(at): BillingSampleEntry{Regions: BillingRegionEntry{"eu-west-1": instances}, Interval: time.Hour},
+	}})
+	region := report.Regions["eu-west-1"]
+	if region.Cost != 0 {
+		t.Errorf("expected nothing to be priced at the %s rates, got %.4f", DefaultRegion, region.Cost)
+	}
+	if region.UnknownCostHours != 4 {
+		t.Errorf("expected the hours of every resource to be unpriced, got %.2f", region.UnknownCostHours)
+	}
+}
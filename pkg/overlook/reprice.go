@@ -0,0 +1,88 @@
+package overlook
+
+import (
+	"fmt"
+	"time"
+)
+
+// Pricing modes of reports
+const (
+	// PricingModeRecorded prices instances at the rate recorded in the snapshot when it was sampled or repriced
+	PricingModeRecorded = "recorded"
+	// PricingModeCurrent prices instances from the active price list
+	PricingModeCurrent = "current"
+)
+
+// reportPricingMode is how GetReport prices instances
+var reportPricingMode = PricingModeCurrent
+
+// SetReportPricingMode chooses how GetReport prices instances, snapshots recorded without a rate
+// are always priced from the active price list
+func SetReportPricingMode(mode string) error {
+	switch mode {
+	case PricingModeRecorded, PricingModeCurrent:
+		reportPricingMode = mode
+		return nil
+	}
+	return fmt.Errorf("unknown pricing mode %q, expected %s or %s", mode, PricingModeRecorded, PricingModeCurrent)
+}
+
+// RepriceDailyEntry prices every instance in dailyEntry again from the active price list and records its
+// version in the snapshots priced from it, it returns the number of snapshots repriced
+func RepriceDailyEntry(dailyEntry BillingDailyEntry) int {
+	repriced := 0
+	for date, dayEntry := range dailyEntry {
+		for key, sample := range dayEntry {
+			at := sampleTime(date, key)
+			for _, regionEntry := range sample.Regions {
+				for id, b := range regionEntry {
+					if !b.IsInstance() {
+						continue
+					}
+					cost, source, err := currentSnapshotCostPerHour(at, b)
+					b.Unpriced = err != nil
+					b.CostPerHour = cost
+					b.CurrentCost = cost * b.HoursUp
+					// A recorded rate keeps the version it was priced with
+					if source != PriceSourceRecorded {
+						b.PriceListVersion = sourcePriceListVersion(source)
+					}
+					regionEntry[id] = b
+					repriced++
+				}
+			}
+		}
+	}
+	return repriced
+}
+
+// RepriceSnapshots prices the instances in the snapshots of every day from through to again from the
+// active price list and returns the number of snapshots repriced
+func RepriceSnapshots(store SnapshotStore, from time.Time, to time.Time) (int, error) {
+	unlock, err := lockStore(store)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	days, err := store.Days()
+	if err != nil {
+		return 0, err
+	}
+	filter := SnapshotFilter{From: from, To: to}
+	repriced := 0
+	for _, day := range days {
+		if !filter.includesDay(day) {
+			continue
+		}
+		dailyEntry, err := store.Read(day)
+		if err != nil {
+			return repriced, err
+		}
+		repriced += RepriceDailyEntry(dailyEntry)
+		if err := store.Write(day, dailyEntry); err != nil {
+			return repriced, err
+		}
+	}
+	return repriced, nil
+}
@@ -0,0 +1,56 @@
+package overlook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepriceDailyEntryRecordsPriceListVersionOfItsSource(t *testing.T) {
+	usePriceList(t, &PriceList{Version: "20210301000000", Prices: map[string]float64{
+		PriceKey{"eu-west-1", "m5.large", DefaultOperatingSystem, DefaultTenancy}.String(): 0.107,
+	}})
+	at := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	spotPrices := NewSpotPriceHistory()
+	spotPrices.Add("us-east-1a", "m5.large", "Linux/UNIX", SpotPricePoint{Timestamp: at.Add(-time.Hour), Price: 0.03})
+	defer UseSpotPriceHistory(activeSpotPrices)
+	UseSpotPriceHistory(spotPrices)
+
+	snapshots := map[string]BillingSnapshot{
+		"price-list": {InstanceType: "m5.large", Region: "eu-west-1", AvailabilityZone: "eu-west-1a", HoursUp: 1},
+		"built-in":   {InstanceType: "m5.large", Region: "us-east-1", AvailabilityZone: "us-east-1a", HoursUp: 1},
+		"spot": {InstanceType: "m5.large", Region: "us-east-1", AvailabilityZone: "us-east-1a", Lifecycle: LifecycleSpot,
+			HoursUp: 1, PriceListVersion: "20200101000000"},
+		"recorded": {InstanceType: "m5.large", Region: "eu-west-1", AvailabilityZone: "eu-west-1b", Lifecycle: LifecycleSpot,
+			HoursUp: 1, CostPerHour: 0.04, PriceListVersion: "20200101000000"},
+		"unpriced": {InstanceType: "x9.unknown", Region: "us-east-1", AvailabilityZone: "us-east-1a", HoursUp: 1,
+			PriceListVersion: "20200101000000"},
+	}
+	instances := make(BillingInstancesEntry)
+	for id, b := range snapshots {
+		b.ID = id
+		instances[id] = b
+	}
+	dailyEntry := BillingDailyEntry{"2021-03-01": BillingTimeEntry{
+		sampleKey(at): BillingSampleEntry{Regions: BillingRegionEntry{"us-east-1": instances}, Interval: time.Hour},
+	}}
+	if repriced := RepriceDailyEntry(dailyEntry); repriced != len(snapshots) {
+		t.Errorf("expected %d snapshots repriced, got %d", len(snapshots), repriced)
+	}
+
+	expected := map[string]struct {
+		cost    float64
+		version string
+	}{
+		"price-list": {0.107, "20210301000000"},
+		"built-in":   {0.096, BuiltinPriceListVersion},
+		"spot":       {0.03, ""},
+		// A recorded spot price keeps the version it was priced with
+		"recorded": {0.04, "20200101000000"},
+		"unpriced": {0, ""},
+	}
+	for id, b := range dailyEntry["2021-03-01"][sampleKey(at)].Regions["us-east-1"] {
+		if b.CostPerHour != expected[id].cost || b.PriceListVersion != expected[id].version {
+			t.Errorf("expected %s to be priced at %v from %q, got %v from %q", id, expected[id].cost, expected[id].version, b.CostPerHour, b.PriceListVersion)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package overlook
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Types of reserved capacity a Reservation describes
+const (
+	ReservationTypeReservedInstance = "reserved-instance"
+	ReservationTypeSavingsPlan      = "savings-plan"
+)
+
+// hoursPerYear is used to amortize upfront payments over the term of a reservation
+const hoursPerYear = 8760
+
+// reservationDateLayout is the layout of the start date of a reservation in the config file
+const reservationDateLayout = "2006-01-02"
+
+// Reservation is a Reserved Instance purchase or Savings Plan, read from the "reservations" list in the config file
+type Reservation struct {
+	// Name identifies the reservation in reports
+	Name string `mapstructure:"name"`
+	// Type is reserved-instance or savings-plan
+	Type string `mapstructure:"type"`
+	// InstanceType is the type a Reserved Instance applies to
+	InstanceType string `mapstructure:"instance_type"`
+	// Region limits the instances covered, a Savings Plan without a region covers every region
+	Region string `mapstructure:"region"`
+	// OperatingSystem is the operating system of the price list a Reserved Instance applies to, Linux when not given
+	OperatingSystem string `mapstructure:"operating_system"`
+	// Tenancy is the tenancy of the price list a Reserved Instance applies to, Shared when not given
+	Tenancy string `mapstructure:"tenancy"`
+	// Count is the number of instances a Reserved Instance purchase covers
+	Count int `mapstructure:"count"`
+	// Term is 1y or 3y
+	Term string `mapstructure:"term"`
+	// Start is the first day of the term as YYYY-MM-DD, reservations without a start are always active
+	Start string `mapstructure:"start"`
+	// Upfront is the total amount paid upfront, amortized over every hour of the term
+	Upfront float64 `mapstructure:"upfront"`
+	// Hourly is the recurring hourly fee, per instance for Reserved Instances
+	Hourly float64 `mapstructure:"hourly"`
+	// Discount is the percentage a Savings Plan takes off on-demand rates
+	Discount float64 `mapstructure:"discount"`
+}
+
+// activeReservations apply to the usage in reports
+var activeReservations []Reservation
+
+// SetReservations replaces the reservations applied to the usage in reports
+func SetReservations(reservations []Reservation) error {
+	for _, r := range reservations {
+		if _, err := r.termHours(); err != nil {
+			return err
+		}
+		if r.Start != "" {
+			if _, err := time.Parse(reservationDateLayout, r.Start); err != nil {
+				return fmt.Errorf("reservation %s: start must be YYYY-MM-DD: %v", r.Name, err)
+			}
+		}
+		switch r.Type {
+		case ReservationTypeReservedInstance:
+			if r.InstanceType == "" || r.Region == "" || r.Count < 1 {
+				return fmt.Errorf("reservation %s: a reserved instance needs an instance_type, region and count", r.Name)
+			}
+		case ReservationTypeSavingsPlan:
+			if r.Discount <= 0 || r.Discount >= 100 {
+				return fmt.Errorf("reservation %s: a savings plan needs a discount between 0 and 100", r.Name)
+			}
+		default:
+			return fmt.Errorf("reservation %s: unknown type: %s", r.Name, r.Type)
+		}
+	}
+	activeReservations = reservations
+	return nil
+}
+
+func (r Reservation) termHours() (float64, error) {
+	switch r.Term {
+	case "1y":
+		return hoursPerYear, nil
+	case "3y":
+		return 3 * hoursPerYear, nil
+	}
+	return 0, fmt.Errorf("reservation %s: term must be 1y or 3y, found %q", r.Name, r.Term)
+}
+
+// AmortizedHourlyCost is what the reservation costs every hour of its term whether it is used or not.
+// For a Savings Plan this is also the hourly commitment spent on covered usage.
+func (r Reservation) AmortizedHourlyCost() float64 {
+	termHours, err := r.termHours()
+	if err != nil {
+		return 0
+	}
+	if r.Type == ReservationTypeReservedInstance {
+		return r.Upfront/termHours + r.Hourly*float64(r.Count)
+	}
+	return r.Upfront/termHours + r.Hourly
+}
+
+// priceKey identifies the on-demand rate of the instances a Reserved Instance applies to
+func (r Reservation) priceKey() PriceKey {
+	key := PriceKey{Region: r.Region, InstanceType: r.InstanceType, OperatingSystem: r.OperatingSystem, Tenancy: r.Tenancy}
+	if key.OperatingSystem == "" {
+		key.OperatingSystem = DefaultOperatingSystem
+	}
+	if key.Tenancy == "" {
+		key.Tenancy = DefaultTenancy
+	}
+	return key
+}
+
+// activeOn reports whether day falls within the term of the reservation
+func (r Reservation) activeOn(day time.Time) bool {
+	if r.Start == "" {
+		return true
+	}
+	start, err := time.Parse(reservationDateLayout, r.Start)
+	if err != nil {
+		return false
+	}
+	termHours, _ := r.termHours()
+	end := start.Add(time.Duration(termHours) * time.Hour)
+	return !day.Before(start) && day.Before(end)
+}
+
+// capacityHours is what the reservation can cover in an hour, instances for Reserved Instances
+// and the commitment for Savings Plans
+func (r Reservation) capacityHours() float64 {
+	if r.Type == ReservationTypeReservedInstance {
+		return float64(r.Count)
+	}
+	return r.AmortizedHourlyCost()
+}
+
+// instanceUsage is a running instance in a sample along with the rate it is billed at
+type instanceUsage struct {
+	Provider     string
+	Account      string
+	Lifecycle    string
+	Key          PriceKey
+	OnDemandCost float64
+}
+
+// reservable reports whether reservations can cover the instance, spot instances are never covered
+func (u instanceUsage) reservable() bool {
+	return u.Provider == ProviderAWS && u.Lifecycle != LifecycleSpot
+}
+
+// reservationCoverage accumulates how reservations covered the usage of a day
+type reservationCoverage struct {
+	reservations []Reservation
+	usage        []ReportReservation
+	covered      float64
+	uncovered    float64
+	effective    float64
+}
+
+func newReservationCoverage(date string) *reservationCoverage {
+	c := &reservationCoverage{}
+	day, err := parseDay(date)
+	if err != nil {
+		return c
+	}
+	for _, r := range activeReservations {
+		if r.activeOn(day) {
+			c.reservations = append(c.reservations, r)
+			c.usage = append(c.usage, ReportReservation{Name: r.Name, Type: r.Type})
+		}
+	}
+	return c
+}
+
+// addSample applies the reservations to the instances running in a sample standing for hours.
+// Reserved Instances are applied first, then Savings Plans spend their commitment on the most
+// expensive remaining usage.
+func (c *reservationCoverage) addSample(usage []instanceUsage, hours float64) {
+	sort.Slice(usage, func(i, j int) bool { return usage[i].OnDemandCost > usage[j].OnDemandCost })
+	remaining := make([]float64, len(usage))
+	for i, u := range usage {
+		remaining[i] = u.OnDemandCost * hours
+	}
+
+	for i, r := range c.reservations {
+		c.usage[i].Hours += hours
+		c.usage[i].Capacity += r.capacityHours() * hours
+		c.usage[i].Cost += r.AmortizedHourlyCost() * hours
+		// Reservations are not tied to an account, the global discount applies to them
+		c.effective += r.AmortizedHourlyCost() * hours * (1 - activePricingConfig.Discount/100)
+		if r.Type != ReservationTypeReservedInstance {
+			continue
+		}
+		key := r.priceKey()
+		available := r.Count
+		for j, u := range usage {
+			if available == 0 {
+				break
+			}
+			if remaining[j] > 0 && u.reservable() && u.Key == key {
+				remaining[j] = 0
+				available--
+				c.usage[i].Used += hours
+				c.usage[i].CoveredHours += hours
+			}
+		}
+	}
+
+	for i, r := range c.reservations {
+		if r.Type != ReservationTypeSavingsPlan {
+			continue
+		}
+		budget := r.AmortizedHourlyCost() * hours
+		rate := 1 - r.Discount/100
+		for j, u := range usage {
+			if budget <= 0 {
+				break
+			}
+			if remaining[j] == 0 || !u.reservable() || (r.Region != "" && u.Key.Region != r.Region) {
+				continue
+			}
+			discounted := remaining[j] * rate
+			if discounted <= budget {
+				budget -= discounted
+				c.usage[i].Used += discounted
+				c.usage[i].CoveredHours += hours
+				remaining[j] = 0
+				continue
+			}
+			// The commitment runs out part way through this instance, the rest is billed on-demand
+			remaining[j] -= budget / rate
+			c.usage[i].Used += budget
+			budget = 0
+		}
+	}
+
+	for j := range usage {
+		if remaining[j] == 0 {
+			c.covered += hours
+		} else {
+			c.uncovered += hours
+			c.effective += remaining[j] * (1 - accountDiscount(usage[j].Account)/100)
+		}
+	}
+}
+
+// apply records the coverage in the report, EffectiveInstanceCost replaces the on-demand instance cost
+func (c *reservationCoverage) apply(report *ReportDaily) {
+	report.CoveredHours = c.covered
+	report.UncoveredHours = c.uncovered
+	report.EffectiveInstanceCost = c.effective
+	for _, u := range c.usage {
+		if u.Capacity > 0 {
+			u.Utilization = 100 * u.Used / u.Capacity
+		}
+		report.Reservations = append(report.Reservations, u)
+	}
+}
@@ -0,0 +1,160 @@
+package overlook
+
+import (
+	"math"
+	"testing"
+)
+
+func testUsage(instanceType string, operatingSystem string, tenancy string, cost float64) instanceUsage {
+	return instanceUsage{
+		Provider:     ProviderAWS,
+		Key:          PriceKey{DefaultRegion, instanceType, operatingSystem, tenancy},
+		OnDemandCost: cost,
+	}
+}
+
+func TestReservationCoverage(t *testing.T) {
+	reservedInstance := Reservation{Name: "ri", Type: ReservationTypeReservedInstance, InstanceType: "m5.large",
+		Region: DefaultRegion, Count: 1, Term: "1y", Hourly: 0.05}
+	windowsReservedInstance := reservedInstance
+	windowsReservedInstance.OperatingSystem = "Windows"
+	savingsPlan := Reservation{Name: "sp", Type: ReservationTypeSavingsPlan, Term: "1y", Hourly: 0.1, Discount: 50}
+	otherRegionSavingsPlan := savingsPlan
+	otherRegionSavingsPlan.Region = "eu-west-1"
+	spot := testUsage("m5.large", DefaultOperatingSystem, DefaultTenancy, 0.03)
+	spot.Lifecycle = LifecycleSpot
+
+	tests := []struct {
+		name         string
+		reservation  Reservation
+		usage        []instanceUsage
+		covered      float64
+		uncovered    float64
+		effective    float64
+		coveredHours float64
+		used         float64
+	}{
+		{
+			name:         "reserved instance covers a matching instance",
+			reservation:  reservedInstance,
+			usage:        []instanceUsage{testUsage("m5.large", DefaultOperatingSystem, DefaultTenancy, 0.096)},
+			covered:      2,
+			effective:    0.05 * 2,
+			coveredHours: 2,
+			used:         2,
+		},
+		{
+			name:        "reserved instance leaves out another operating system",
+			reservation: reservedInstance,
+			usage:       []instanceUsage{testUsage("m5.large", "Windows", DefaultTenancy, 0.188)},
+			uncovered:   2,
+			effective:   0.05*2 + 0.188*2,
+		},
+		{
+			name:        "reserved instance leaves out another tenancy",
+			reservation: reservedInstance,
+			usage:       []instanceUsage{testUsage("m5.large", DefaultOperatingSystem, "Dedicated", 0.106)},
+			uncovered:   2,
+			effective:   0.05*2 + 0.106*2,
+		},
+		{
+			name:        "reserved instance for an operating system covers only it",
+			reservation: windowsReservedInstance,
+			usage: []instanceUsage{
+				testUsage("m5.large", DefaultOperatingSystem, DefaultTenancy, 0.096),
+				testUsage("m5.large", "Windows", DefaultTenancy, 0.188),
+			},
+			covered:      2,
+			uncovered:    2,
+			effective:    0.05*2 + 0.096*2,
+			coveredHours: 2,
+			used:         2,
+		},
+		{
+			name:        "reserved instance leaves out spot instances",
+			reservation: reservedInstance,
+			usage:       []instanceUsage{spot},
+			uncovered:   2,
+			effective:   0.05*2 + 0.03*2,
+		},
+		{
+			name:         "savings plan covers usage within its commitment",
+			reservation:  savingsPlan,
+			usage:        []instanceUsage{testUsage("t3.micro", DefaultOperatingSystem, DefaultTenancy, 0.0104)},
+			covered:      2,
+			effective:    0.1 * 2,
+			coveredHours: 2,
+			used:         0.0104 * 0.5 * 2,
+		},
+		{
+			// The commitment of 0.2 covers 0.4 of on-demand usage, the other 0.2 is billed on-demand
+			name:        "savings plan partly covers usage beyond its commitment",
+			reservation: savingsPlan,
+			usage:       []instanceUsage{testUsage("m5.xlarge", DefaultOperatingSystem, DefaultTenancy, 0.3)},
+			uncovered:   2,
+			effective:   0.1*2 + 0.2,
+			used:        0.1 * 2,
+		},
+		{
+			name:        "savings plan leaves out spot instances",
+			reservation: savingsPlan,
+			usage:       []instanceUsage{spot},
+			uncovered:   2,
+			effective:   0.1*2 + 0.03*2,
+		},
+		{
+			name:        "savings plan leaves out other regions",
+			reservation: otherRegionSavingsPlan,
+			usage:       []instanceUsage{testUsage("t3.micro", DefaultOperatingSystem, DefaultTenancy, 0.0104)},
+			uncovered:   2,
+			effective:   0.1*2 + 0.0104*2,
+		},
+	}
+	for _, tc := range tests {
+		c := &reservationCoverage{
+			reservations: []Reservation{tc.reservation},
+			usage:        []ReportReservation{{Name: tc.reservation.Name, Type: tc.reservation.Type}},
+		}
+		c.addSample(tc.usage, 2)
+		report := ReportDaily{}
+		c.apply(&report)
+
+		if math.Abs(report.CoveredHours-tc.covered) > 1e-9 || math.Abs(report.UncoveredHours-tc.uncovered) > 1e-9 ||
+			math.Abs(report.EffectiveInstanceCost-tc.effective) > 1e-9 {
+			t.Errorf("%s: expected %v covered, %v uncovered and %v effective cost, got %v, %v and %v", tc.name,
+				tc.covered, tc.uncovered, tc.effective, report.CoveredHours, report.UncoveredHours, report.EffectiveInstanceCost)
+		}
+		if len(report.Reservations) != 1 {
+			t.Fatalf("%s: expected the reservation in the report, got %+v", tc.name, report.Reservations)
+		}
+		r := report.Reservations[0]
+		capacity := tc.reservation.capacityHours() * 2
+		if r.Hours != 2 || r.Capacity != capacity || math.Abs(r.Cost-tc.reservation.AmortizedHourlyCost()*2) > 1e-9 {
+			t.Errorf("%s: expected the reservation to stand for 2 hours of %v capacity, got %+v", tc.name, capacity, r)
+		}
+		if r.CoveredHours != tc.coveredHours || math.Abs(r.Used-tc.used) > 1e-9 || math.Abs(r.Utilization-100*tc.used/capacity) > 1e-9 {
+			t.Errorf("%s: expected %v hours covered using %v, got %+v", tc.name, tc.coveredHours, tc.used, r)
+		}
+	}
+}
+
+func TestReservationCoverageAppliesReservedInstancesBeforeSavingsPlans(t *testing.T) {
+	reservations := []Reservation{
+		{Name: "sp", Type: ReservationTypeSavingsPlan, Term: "1y", Hourly: 0.1, Discount: 50},
+		{Name: "ri", Type: ReservationTypeReservedInstance, InstanceType: "m5.large", Region: DefaultRegion, Count: 1, Term: "1y", Hourly: 0.05},
+	}
+	c := &reservationCoverage{reservations: reservations, usage: []ReportReservation{{Name: "sp"}, {Name: "ri"}}}
+	c.addSample([]instanceUsage{
+		testUsage("m5.large", DefaultOperatingSystem, DefaultTenancy, 0.096),
+		testUsage("t3.micro", DefaultOperatingSystem, DefaultTenancy, 0.0104),
+	}, 1)
+	report := ReportDaily{}
+	c.apply(&report)
+
+	if report.CoveredHours != 2 || report.UncoveredHours != 0 {
+		t.Errorf("expected both instances covered, got %v covered and %v uncovered", report.CoveredHours, report.UncoveredHours)
+	}
+	if sp, ri := report.Reservations[0], report.Reservations[1]; ri.Used != 1 || math.Abs(sp.Used-0.0104*0.5) > 1e-9 {
+		t.Errorf("expected the reserved instance to cover m5.large and the savings plan t3.micro, got %+v and %+v", ri, sp)
+	}
+}
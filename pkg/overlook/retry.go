@@ -0,0 +1,194 @@
+package overlook
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy controls how calls to AWS, and other cloud providers, are timed out and retried
+type RetryPolicy struct {
+	// MaxRetries is the number of times a failed call is retried
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry, it doubles with every retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between retries
+	MaxDelay time.Duration
+	// CallTimeout limits each attempt of a call, zero means no limit
+	CallTimeout time.Duration
+}
+
+// DefaultRetryPolicy is used unless watch is told otherwise
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:  5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	CallTimeout: 2 * time.Minute,
+}
+
+// CallStats records the calls made to AWS while collecting a region
+type CallStats struct {
+	Calls        int
+	Retries      int
+	Failures     int
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+func (s CallStats) String() string {
+	var avg time.Duration
+	if s.Calls > 0 {
+		avg = s.TotalLatency / time.Duration(s.Calls)
+	}
+	return fmt.Sprintf("Calls: %d, Retries: %d, Failures: %d, Avg Latency: %s, Max Latency: %s, Total Latency: %s",
+		s.Calls, s.Retries, s.Failures, avg, s.MaxLatency, s.TotalLatency)
+}
+
+func (s *CallStats) record(latency time.Duration) {
+	s.Calls++
+	s.TotalLatency += latency
+	if latency > s.MaxLatency {
+		s.MaxLatency = latency
+	}
+}
+
+// IsRetryableError reports whether err is from throttling or a server side error
+func IsRetryableError(err error) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() >= 500 {
+		return true
+	}
+	if httpErr, ok := err.(*HTTPError); ok {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	return false
+}
+
+// Do calls fn with a context limited to CallTimeout, it is used for calls which are not made with the
+// AWS SDK, AWS clients are instrumented to retry every request instead. Throttling, server errors and calls
+// which timed out are retried with exponential backoff and full jitter until MaxRetries is reached.
+func (p RetryPolicy) Do(ctx aws.Context, stats *CallStats, fn func(ctx aws.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		callCtx, cancel := ctx, context.CancelFunc(func() {})
+		if p.CallTimeout > 0 {
+			callCtx, cancel = context.WithTimeout(ctx, p.CallTimeout)
+		}
+		start := time.Now()
+		err := fn(callCtx)
+		timedOut := callCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+		cancel()
+		stats.record(time.Since(start))
+		if err == nil {
+			return nil
+		}
+		if attempt >= p.MaxRetries || !(timedOut || IsRetryableError(err)) {
+			stats.Failures++
+			return err
+		}
+
+		stats.Retries++
+		delay := p.backoff(attempt)
+		log.Warnln("Retrying in", delay, "after error:", err)
+		select {
+		case <-ctx.Done():
+			stats.Failures++
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns a random delay up to BaseDelay * 2^attempt, capped at MaxDelay
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.MaxDelay
+	if attempt < 32 {
+		if d := p.BaseDelay << uint(attempt); d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// Retryer retries the requests of the AWS SDK following Policy, it is the request.Retryer of
+// clients instrumented with Instrument
+type Retryer struct {
+	Policy RetryPolicy
+}
+
+// MaxRetries returns the number of times a failed request is retried
+func (r Retryer) MaxRetries() int {
+	return r.Policy.MaxRetries
+}
+
+// RetryRules returns the backoff before retrying req
+func (r Retryer) RetryRules(req *request.Request) time.Duration {
+	return r.Policy.backoff(req.RetryCount)
+}
+
+// ShouldRetry reports whether req failed with throttling, a server side error or by timing out,
+// a request whose context was cancelled is never retried
+func (r Retryer) ShouldRetry(req *request.Request) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.HTTPRequest != nil && req.HTTPRequest.Context().Err() == context.DeadlineExceeded {
+		return true
+	}
+	return IsRetryableError(req.Error) || req.IsErrorRetryable() || req.IsErrorThrottle()
+}
+
+// Instrument makes the requests of an AWS client follow the policy and records them in stats. Every
+// request, such as each page of a paginated call, is retried on its own, so a page which is throttled
+// does not start the pagination over, and every attempt of a request is limited to CallTimeout.
+func (p RetryPolicy) Instrument(c *client.Client, stats *CallStats) {
+	c.Retryer = Retryer{Policy: p}
+	// The policy decides on every failed attempt, even those the SDK has already judged
+	c.Config.EnforceShouldRetryCheck = aws.Bool(true)
+	c.Handlers.Validate.PushBack(func(r *request.Request) {
+		r.ApplyOptions(p.attemptTimeout())
+	})
+	c.Handlers.CompleteAttempt.PushBack(func(r *request.Request) {
+		stats.record(time.Since(r.AttemptTime))
+	})
+	c.Handlers.Complete.PushBack(func(r *request.Request) {
+		stats.Retries += r.RetryCount
+		if r.Error != nil {
+			stats.Failures++
+		}
+	})
+}
+
+// attemptTimeout returns an option limiting every attempt of a request to CallTimeout, the
+// context of the request still limits all of its attempts together
+func (p RetryPolicy) attemptTimeout() request.Option {
+	return func(r *request.Request) {
+		if p.CallTimeout <= 0 {
+			return
+		}
+		cancel := context.CancelFunc(func() {})
+		r.Handlers.Send.PushFront(func(r *request.Request) {
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(r.Context(), p.CallTimeout)
+			r.HTTPRequest = r.HTTPRequest.WithContext(ctx)
+		})
+		// Attempts complete once their response is read, the next attempt is signed before it is sent
+		// so it must not see the context of this one
+		r.Handlers.CompleteAttempt.PushBack(func(r *request.Request) {
+			cancel()
+			r.HTTPRequest = r.HTTPRequest.WithContext(r.Context())
+		})
+	}
+}
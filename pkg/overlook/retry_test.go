@@ -0,0 +1,139 @@
+package overlook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const describeInstancesPage = `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>test</requestId>
+  <reservationSet><item><instancesSet><item>
+    <instanceId>%s</instanceId>
+    <instanceType>m5.large</instanceType>
+    <launchTime>2020-01-01T00:00:00.000Z</launchTime>
+    <placement><availabilityZone>us-east-1a</availabilityZone></placement>
+    <instanceState><code>16</code><name>running</name></instanceState>
+  </item></instancesSet></item></reservationSet>
+  %s
+</DescribeInstancesResponse>`
+
+const throttledResponse = `<Response><Errors><Error><Code>RequestLimitExceeded</Code><Message>Request limit exceeded.</Message></Error></Errors><RequestID>test</RequestID></Response>`
+
+// fakeEC2Server serves two pages of DescribeInstances, the first attempt at the second page fails with fail
+type fakeEC2Server struct {
+	mu       sync.Mutex
+	requests map[string]int
+	fail     func(w http.ResponseWriter)
+}
+
+func (f *fakeEC2Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := r.Form.Get("NextToken")
+	f.mu.Lock()
+	f.requests[token]++
+	attempt := f.requests[token]
+	f.mu.Unlock()
+
+	switch token {
+	case "":
+		fmt.Fprintf(w, describeInstancesPage, "i-1", "<nextToken>page-2</nextToken>")
+	case "page-2":
+		if attempt == 1 {
+			f.fail(w)
+			return
+		}
+		fmt.Fprintf(w, describeInstancesPage, "i-2", "")
+	}
+}
+
+func newTestEC2(t *testing.T, url string, policy RetryPolicy, stats *CallStats) *ec2.EC2 {
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(url).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := ec2.New(sess)
+	policy.Instrument(svc.Client, stats)
+	return svc
+}
+
+func TestInstrumentRetriesThrottledPage(t *testing.T) {
+	fake := &fakeEC2Server{requests: make(map[string]int), fail: func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, throttledResponse)
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var stats CallStats
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, CallTimeout: time.Second}
+	instances, err := GetInstances(context.Background(), newTestEC2(t, srv.URL, policy, &stats), "us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("expected 2 instances, got %d", len(instances))
+	}
+	if fake.requests[""] != 1 {
+		t.Errorf("the first page was requested %d times, pagination started over", fake.requests[""])
+	}
+	if fake.requests["page-2"] != 2 {
+		t.Errorf("expected the throttled page to be requested twice, got %d", fake.requests["page-2"])
+	}
+	if stats.Calls != 3 || stats.Retries != 1 || stats.Failures != 0 {
+		t.Errorf("unexpected stats: %s", stats)
+	}
+}
+
+func TestInstrumentTimesOutEachAttempt(t *testing.T) {
+	fake := &fakeEC2Server{requests: make(map[string]int), fail: func(w http.ResponseWriter) {
+		time.Sleep(500 * time.Millisecond)
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var stats CallStats
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, CallTimeout: 100 * time.Millisecond}
+	instances, err := GetInstances(context.Background(), newTestEC2(t, srv.URL, policy, &stats), "us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("expected 2 instances, got %d", len(instances))
+	}
+	if stats.Retries != 1 {
+		t.Errorf("expected the attempt that timed out to be retried once, got %s", stats)
+	}
+}
+
+func TestInstrumentGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, throttledResponse)
+	}))
+	defer srv.Close()
+
+	var stats CallStats
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	if _, err := GetInstances(context.Background(), newTestEC2(t, srv.URL, policy, &stats), "us-east-1"); err == nil {
+		t.Fatal("expected an error once the retries are used up")
+	}
+	if stats.Calls != 3 || stats.Retries != 2 || stats.Failures != 1 {
+		t.Errorf("unexpected stats: %s", stats)
+	}
+}
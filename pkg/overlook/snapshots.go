@@ -1,14 +1,12 @@
 package overlook
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
-	"os"
-	"path"
 	"path/filepath"
-	"sort"
 	"time"
 )
 
@@ -19,120 +17,216 @@ func GetBillingDataLocation() string {
 	return s
 }
 
-// GetBillingDataSortedFileNames returns a slice of billing data file names sorted by date
-func GetBillingDataSortedFileNames() []string {
-	var billingDataPath = GetBillingDataLocation()
-	if _, err := os.Stat(billingDataPath); os.IsNotExist(err) {
-		log.Infoln("Billing directory of", billingDataPath, " doesn't exist")
-		panic(err)
+// snapshotFile is the layout of a snapshot file, Checksum is the SHA-256 of Days so a file that was
+// corrupted is detected when it is read. Files written before the checksum hold Days alone.
+type snapshotFile struct {
+	Checksum string
+	Days     json.RawMessage
+}
+
+// CorruptSnapshotError is returned when a snapshot file does not match its checksum or can not be parsed
+type CorruptSnapshotError struct {
+	File   string
+	Reason string
+}
+
+func (e *CorruptSnapshotError) Error() string {
+	return fmt.Sprintf("snapshot file %s is corrupted: %s", e.File, e.Reason)
+}
+
+func snapshotChecksum(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+// ReadSnapshotInfo returns a BillingDailyEntry for given filename, empty when the file does not exist
+func ReadSnapshotInfo(filename string) (BillingDailyEntry, error) {
+	if !Exists(filename) {
+		return make(BillingDailyEntry), nil
 	}
-	// Get all files
-	files, err := ioutil.ReadDir(billingDataPath)
+	byteValue, err := ioutil.ReadFile(filename)
 	if err != nil {
-		log.Fatal(err)
-	}
-	fileNames := make([]string, 0)
-	for _, f := range files {
-		fileNames = append(fileNames, f.Name())
-	}
-	sort.Slice(fileNames, func(i, j int) bool { return fileNames[i] > fileNames[j] })
-
-	absFileNames := make([]string, 0)
-	for _, f := range fileNames {
-		absFileNames = append(absFileNames, path.Join(billingDataPath, f))
+		return nil, err
 	}
-	return absFileNames
+	return decodeSnapshot(filename, byteValue)
 }
 
-// ReadSnapshotInfo returns a BillingDailyEntry for given filename
-func ReadSnapshotInfo(filename string) BillingDailyEntry {
+// decodeSnapshot parses the snapshot file named name, a file that does not match its checksum or
+// can not be parsed is a *CorruptSnapshotError
+func decodeSnapshot(name string, data []byte) (BillingDailyEntry, error) {
 	dailyEntry := make(BillingDailyEntry)
+	if len(data) == 0 {
+		return dailyEntry, nil
+	}
 
-	if Exists(filename) {
-		inFile, err := os.OpenFile(filename, os.O_RDONLY, 0660)
-		if err != nil {
-			panic(err)
-		}
-		defer CheckClose(inFile)
-
-		// Read any existing data if file is not empty
-		byteValue, err := ioutil.ReadAll(inFile)
-		if err != nil {
-			panic(err)
-		}
-		if len(byteValue) > 0 {
-			err = json.Unmarshal(byteValue, &dailyEntry)
-			if err != nil {
-				panic(err)
-			}
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, &CorruptSnapshotError{File: name, Reason: err.Error()}
+	}
+	days := data
+	if len(file.Days) > 0 {
+		if snapshotChecksum(file.Days) != file.Checksum {
+			return nil, &CorruptSnapshotError{File: name, Reason: "checksum does not match"}
 		}
+		days = file.Days
 	}
-	return dailyEntry
+	if err := json.Unmarshal(days, &dailyEntry); err != nil {
+		return nil, &CorruptSnapshotError{File: name, Reason: err.Error()}
+	}
+	return dailyEntry, nil
 }
 
-func writeSnapshotInfo(filename string, dailyEntry BillingDailyEntry) (err error) {
-	//
-	// Want to delete any existing data if present
-	//
-	if Exists(filename) {
-		err = os.Truncate(filename, 0)
-		if err != nil {
-			panic(err)
-		}
+// encodeSnapshot returns the snapshot file of dailyEntry along with its checksum
+func encodeSnapshot(dailyEntry BillingDailyEntry) ([]byte, error) {
+	days, err := json.Marshal(dailyEntry)
+	if err != nil {
+		return nil, err
 	}
+	return json.Marshal(snapshotFile{Checksum: snapshotChecksum(days), Days: days})
+}
 
-	outFile, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0660)
+// writeSnapshotInfo replaces filename with dailyEntry and its checksum, the file is written in full
+// to a temporary file first so a crash never leaves a partly written day behind
+func writeSnapshotInfo(filename string, dailyEntry BillingDailyEntry) error {
+	data, err := encodeSnapshot(dailyEntry)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
+
+// previousSample returns the most recent sample stored before now, looking back to the
+// previous day's file if needed. sameSample is true when the sample was taken at now and is
+// about to be overwritten, as when a recorded sample is replayed again.
+func previousSample(store SnapshotStore, now time.Time, dailyEntry BillingDailyEntry) (previous BillingRegionEntry, sameSample bool) {
+	ymd := DayOf(now)
+	if sample, ok := dailyEntry[ymd][sampleKey(now)]; ok {
+		return sample.Regions, true
+	}
+	if sample, ok := latestSample(ymd, dailyEntry[ymd], now); ok {
+		return sample.Regions, false
 	}
-	defer CheckClose(outFile)
 
-	var bsJSON []byte
-	bsJSON, err = json.Marshal(dailyEntry)
+	yesterday := DayOf(now.AddDate(0, 0, -1))
+	yesterdayEntry, err := store.Read(yesterday)
 	if err != nil {
-		panic(err)
+		log.Warnln("Unable to read the previous day to detect state changes:", err)
+		return nil, false
 	}
-	_, err = outFile.WriteString(string(bsJSON))
-	if err != nil {
-		panic(err)
+	if sample, ok := latestSample(yesterday, yesterdayEntry[yesterday], now); ok {
+		return sample.Regions, false
 	}
-	err = outFile.Sync()
-	return err
+	return nil, false
 }
 
-// StoreBillingSnapshots will write billing snapshot data to billingDirPath
-func StoreBillingSnapshots(regionInfo []RegionInfo, billingDirPath string) {
-	//
-	// TODO: Add ability to write to S3
-	//
-	now := time.Now()
-	hour := now.Hour()
-	ymd := now.Format("01-02-2006")
-	var dailyEntry BillingDailyEntry
-
-	if _, err := os.Stat(billingDirPath); os.IsNotExist(err) {
-		err = os.MkdirAll(billingDirPath, os.ModePerm)
-		if err != nil {
-			panic(err)
+// latestSample returns the last sample of a day taken before now
+func latestSample(date string, dayEntry BillingTimeEntry, now time.Time) (BillingSampleEntry, bool) {
+	var latest BillingSampleEntry
+	var latestTime time.Time
+	found := false
+	for key, sample := range dayEntry {
+		t := sampleTime(date, key)
+		if t.Before(now) && (!found || t.After(latestTime)) {
+			latest, latestTime, found = sample, t, true
 		}
 	}
+	return latest, found
+}
 
-	snapshotFilename := fmt.Sprintf("%s/%s.json", billingDirPath, ymd)
-	dailyEntry = ReadSnapshotInfo(snapshotFilename)
+// transitionFrom returns the state an instance was in at the previous sample if it has changed since.
+// A transition already recorded in a sample that is overwritten is kept so it is not lost.
+func transitionFrom(previous BillingRegionEntry, sameSample bool, region string, bSnap BillingSnapshot) string {
+	if !bSnap.IsInstance() {
+		return ""
+	}
+	prev, ok := previous[region][bSnap.Key()]
+	if !ok {
+		// Samples stored before snapshots were keyed by account
+		prev, ok = previous[region][bSnap.ID]
+	}
+	if !ok || prev.AccountID != bSnap.AccountID {
+		return ""
+	}
+	if prev.InstanceState() != bSnap.InstanceState() {
+		return prev.InstanceState()
+	}
+	if sameSample {
+		return prev.PreviousState
+	}
+	return ""
+}
 
-	var hourlyEntry BillingHourEntry
+// mergeFailedRegions returns the regions that failed in this sample, keeping failures recorded
+// in the sample it overwrites for regions that were not sampled again
+func mergeFailedRegions(existing []FailedRegion, regionInfo []RegionInfo) []FailedRegion {
+	sampled := make(map[Target]bool)
+	for _, r := range regionInfo {
+		sampled[Target{Provider: r.Provider, AccountID: r.AccountID, Region: r.RegionName}] = true
+	}
+	failed := make([]FailedRegion, 0)
+	for _, f := range existing {
+		provider := f.Provider
+		if provider == "" {
+			provider = ProviderAWS
+		}
+		if !sampled[Target{Provider: provider, AccountID: f.AccountID, Region: f.Region}] {
+			failed = append(failed, f)
+		}
+	}
+	for _, r := range regionInfo {
+		if r.Status == RegionStatusOK {
+			continue
+		}
+		f := FailedRegion{Provider: r.Provider, AccountID: r.AccountID, Region: r.RegionName, Status: r.Status}
+		if r.Err != nil {
+			f.Error = r.Err.Error()
+		}
+		failed = append(failed, f)
+	}
+	return failed
+}
+
+// StoreBillingSnapshots will write billing snapshot data to store as a sample taken now, along with the
+// interval samples are taken at. Other processes are kept from changing the store in between reading
+// and writing the day.
+func StoreBillingSnapshots(regionInfo []RegionInfo, store SnapshotStore, interval time.Duration) error {
+	unlock, err := lockStore(store)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Days and sample times are UTC so they never repeat or skip at a DST change
+	now := Now().UTC().Truncate(time.Second)
+	key := sampleKey(now)
+	ymd := DayOf(now)
+
+	dailyEntry, err := store.Read(ymd)
+	if _, ok := err.(*CorruptSnapshotError); ok {
+		// Rewriting the day would drop the samples already in it
+		return fmt.Errorf("%v, no samples of %s are stored until it is moved aside or restored from a backup", err, ymd)
+	}
+	if err != nil {
+		return err
+	}
+	previous, sameSample := previousSample(store, now, dailyEntry)
+
+	var timeEntry BillingTimeEntry
+	var sampleEntry BillingSampleEntry
 	var regionEntry BillingRegionEntry
 	var ok bool
 
-	hourlyEntry, ok = dailyEntry[ymd]
+	timeEntry, ok = dailyEntry[ymd]
 	if !ok {
-		hourlyEntry = make(BillingHourEntry)
+		timeEntry = make(BillingTimeEntry)
 	}
 
-	regionEntry, ok = hourlyEntry[hour]
+	sampleEntry, ok = timeEntry[key]
 	if !ok {
-		regionEntry = make(BillingRegionEntry)
+		sampleEntry.Regions = make(BillingRegionEntry)
 	}
+	sampleEntry.Interval = interval
+	regionEntry = sampleEntry.Regions
+	sampleEntry.FailedRegions = mergeFailedRegions(sampleEntry.FailedRegions, regionInfo)
 
 	for _, r := range regionInfo {
 		var instancesEntry BillingInstancesEntry
@@ -141,16 +235,17 @@ func StoreBillingSnapshots(regionInfo []RegionInfo, billingDirPath string) {
 			instancesEntry = make(BillingInstancesEntry)
 		}
 		for _, bSnap := range r.BillingSnapshots {
-			instancesEntry[bSnap.ID] = bSnap
+			bSnap.PreviousState = transitionFrom(previous, sameSample, r.RegionName, bSnap)
+			instancesEntry[bSnap.Key()] = bSnap
 		}
 		regionEntry[r.RegionName] = instancesEntry
 	}
 
-	hourlyEntry[hour] = regionEntry
-	dailyEntry[ymd] = hourlyEntry
-
-	err := writeSnapshotInfo(snapshotFilename, dailyEntry)
-	if err != nil {
-		panic(err)
+	if w, ok := store.(SampleWriter); ok {
+		return w.WriteSample(ymd, key, sampleEntry)
 	}
+	timeEntry[key] = sampleEntry
+	dailyEntry[ymd] = timeEntry
+
+	return store.Write(ymd, dailyEntry)
 }
@@ -0,0 +1,60 @@
+package overlook
+
+import (
+	"testing"
+	"time"
+)
+
+// testAccountsRegionInfo returns a sample of two accounts with a resource of the same ID each
+func testAccountsRegionInfo(states ...string) []RegionInfo {
+	regionInfo := make([]RegionInfo, 0)
+	for i, account := range []string{"111111111111", "222222222222"} {
+		regionInfo = append(regionInfo, RegionInfo{
+			Provider:   ProviderAWS,
+			AccountID:  account,
+			RegionName: "us-east-1",
+			Status:     RegionStatusOK,
+			BillingSnapshots: []BillingSnapshot{
+				{ID: "i-1", AccountID: account, InstanceType: "m5.large", Region: "us-east-1", State: states[i]},
+			},
+		})
+	}
+	return regionInfo
+}
+
+func TestStoreBillingSnapshotsKeysSnapshotsByAccount(t *testing.T) {
+	stores := map[string]SnapshotStore{
+		"file":   NewFileSnapshotStore(t.TempDir()),
+		"sqlite": openTestSQLiteStore(t),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			defer func(now func() time.Time) { Now = now }(Now)
+			first := time.Date(2021, 3, 1, 1, 0, 0, 0, time.UTC)
+			Now = func() time.Time { return first }
+			if err := StoreBillingSnapshots(testAccountsRegionInfo(InstanceStateRunning, InstanceStateRunning), store, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			second := first.Add(time.Hour)
+			Now = func() time.Time { return second }
+			if err := StoreBillingSnapshots(testAccountsRegionInfo(InstanceStateStopped, InstanceStateRunning), store, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			dailyEntry, err := store.Read("2021-03-01")
+			if err != nil {
+				t.Fatal(err)
+			}
+			snapshots := dailyEntry["2021-03-01"][sampleKey(second)].Regions["us-east-1"]
+			if len(snapshots) != 2 {
+				t.Fatalf("expected the snapshot of each account, got %v", snapshots)
+			}
+			if b := snapshots["111111111111/i-1"]; b.PreviousState != InstanceStateRunning {
+				t.Errorf("expected the stop in the first account to be recorded, got %+v", b)
+			}
+			if b := snapshots["222222222222/i-1"]; b.PreviousState != "" {
+				t.Errorf("expected no transition in the second account, got %+v", b)
+			}
+		})
+	}
+}
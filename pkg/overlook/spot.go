@@ -0,0 +1,220 @@
+package overlook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	log "github.com/sirupsen/logrus"
+)
+
+// Lifecycles of an instance, instances without a lifecycle are on-demand
+const (
+	LifecycleOnDemand  = "on-demand"
+	LifecycleSpot      = "spot"
+	LifecycleScheduled = "scheduled"
+)
+
+// spotPriceHistoryFileName is where spot prices are cached in the pricing directory, in a directory
+// of its own so it is not taken for a version of the price list
+var spotPriceHistoryFileName = filepath.Join("spot", "history.json")
+
+// legacySpotPriceHistoryFileName is where spot prices were cached among the versions of the price list
+const legacySpotPriceHistoryFileName = "spot.json"
+
+// spotProductDescriptions maps the operating system of the price list to the product description of spot prices
+var spotProductDescriptions = map[string]string{
+	"Linux":   "Linux/UNIX",
+	"RHEL":    "Red Hat Enterprise Linux",
+	"SUSE":    "SUSE Linux",
+	"Windows": "Windows",
+}
+
+// SpotPricePoint is the spot price from Timestamp until the next point
+type SpotPricePoint struct {
+	Timestamp time.Time
+	Price     float64
+}
+
+// SpotPriceHistory has the spot prices of instance types keyed by availability zone,
+// instance type and product description
+type SpotPriceHistory struct {
+	sync.RWMutex
+	Prices map[string][]SpotPricePoint
+}
+
+// NewSpotPriceHistory returns an empty SpotPriceHistory
+func NewSpotPriceHistory() *SpotPriceHistory {
+	return &SpotPriceHistory{Prices: make(map[string][]SpotPricePoint)}
+}
+
+func spotPriceKey(availabilityZone string, instanceType string, productDescription string) string {
+	return availabilityZone + "/" + instanceType + "/" + productDescription
+}
+
+// Add records a spot price, returning false if it was already known
+func (h *SpotPriceHistory) Add(availabilityZone string, instanceType string, productDescription string, point SpotPricePoint) bool {
+	h.Lock()
+	defer h.Unlock()
+	key := spotPriceKey(availabilityZone, instanceType, productDescription)
+	points := h.Prices[key]
+	i := sort.Search(len(points), func(i int) bool { return !points[i].Timestamp.Before(point.Timestamp) })
+	if i < len(points) && points[i].Timestamp.Equal(point.Timestamp) {
+		return false
+	}
+	points = append(points, SpotPricePoint{})
+	copy(points[i+1:], points[i:])
+	points[i] = point
+	h.Prices[key] = points
+	return true
+}
+
+// PriceAt returns the spot price in effect at a time
+func (h *SpotPriceHistory) PriceAt(availabilityZone string, instanceType string, productDescription string, at time.Time) (float64, bool) {
+	h.RLock()
+	defer h.RUnlock()
+	// Instances launched into a VPC on EC2-Classic accounts are priced under the "(Amazon VPC)" products
+	for _, product := range []string{productDescription, productDescription + " (Amazon VPC)"} {
+		points := h.Prices[spotPriceKey(availabilityZone, instanceType, product)]
+		i := sort.Search(len(points), func(i int) bool { return points[i].Timestamp.After(at) })
+		if i > 0 {
+			return points[i-1].Price, true
+		}
+	}
+	return 0, false
+}
+
+// addSpotPrices records the prices of a DescribeSpotPriceHistory response, returning how many were new
+func (h *SpotPriceHistory) addSpotPrices(prices []*ec2.SpotPrice) int {
+	added := 0
+	for _, p := range prices {
+		price, err := strconv.ParseFloat(aws.StringValue(p.SpotPrice), 64)
+		if err != nil || p.Timestamp == nil {
+			continue
+		}
+		if h.Add(aws.StringValue(p.AvailabilityZone), aws.StringValue(p.InstanceType), aws.StringValue(p.ProductDescription),
+			SpotPricePoint{Timestamp: *p.Timestamp, Price: price}) {
+			added++
+		}
+	}
+	return added
+}
+
+// activeSpotPrices are used to price spot instances
+var activeSpotPrices = NewSpotPriceHistory()
+
+// UseSpotPriceHistory makes spot instances priced from h
+func UseSpotPriceHistory(h *SpotPriceHistory) {
+	activeSpotPrices = h
+}
+
+// GetSpotCostPerHour returns the spot price of an instance in an availability zone at a time
+func GetSpotCostPerHour(key PriceKey, availabilityZone string, at time.Time) (float64, error) {
+	operatingSystem := key.OperatingSystem
+	if operatingSystem == "" {
+		operatingSystem = DefaultOperatingSystem
+	}
+	product, ok := spotProductDescriptions[operatingSystem]
+	if !ok {
+		return 0, fmt.Errorf("no spot prices for operating system: %s", operatingSystem)
+	}
+	cost, ok := activeSpotPrices.PriceAt(availabilityZone, key.InstanceType, product, at)
+	if !ok {
+		return 0, fmt.Errorf("no spot price for %s %s in %s at %s", key.InstanceType, product, availabilityZone, at.Format(time.RFC3339))
+	}
+	return cost, nil
+}
+
+// CollectSpotPriceHistory adds the spot prices of the instance types of spot instances in a region since start
+func CollectSpotPriceHistory(ctx aws.Context, svc ec2iface.EC2API, instances []InstanceInfo, start time.Time) error {
+	types := make(map[string]bool)
+	for _, inst := range instances {
+		if inst.Lifecycle == LifecycleSpot {
+			types[inst.InstanceType] = true
+		}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+	input := &ec2.DescribeSpotPriceHistoryInput{StartTime: aws.Time(start)}
+	for t := range types {
+		input.InstanceTypes = append(input.InstanceTypes, aws.String(t))
+	}
+	return svc.DescribeSpotPriceHistoryPagesWithContext(ctx, input,
+		func(page *ec2.DescribeSpotPriceHistoryOutput, lastPage bool) bool {
+			activeSpotPrices.addSpotPrices(page.SpotPriceHistory)
+			return true
+		})
+}
+
+// ReadSpotPriceHistory returns the spot prices cached in the pricing directory, or in its legacy
+// location until the history is written again
+func ReadSpotPriceHistory(pricingDirPath string) (*SpotPriceHistory, error) {
+	data, err := ioutil.ReadFile(filepath.Join(pricingDirPath, spotPriceHistoryFileName))
+	if os.IsNotExist(err) {
+		data, err = ioutil.ReadFile(filepath.Join(pricingDirPath, legacySpotPriceHistoryFileName))
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := NewSpotPriceHistory()
+	if err := json.Unmarshal(data, &h.Prices); err != nil {
+		return nil, fmt.Errorf("unable to parse spot price history: %v", err)
+	}
+	return h, nil
+}
+
+// WriteSpotPriceHistory caches the spot prices of h in the pricing directory and removes the
+// history cached in the legacy location, which was read into h
+func WriteSpotPriceHistory(pricingDirPath string, h *SpotPriceHistory) error {
+	fileName := filepath.Join(pricingDirPath, spotPriceHistoryFileName)
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return err
+	}
+	h.RLock()
+	data, err := json.Marshal(h.Prices)
+	h.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(fileName, data, 0660); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(pricingDirPath, legacySpotPriceHistoryFileName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// StoreSpotPriceHistory caches the spot prices collected while sampling
+func StoreSpotPriceHistory(pricingDirPath string) {
+	activeSpotPrices.RLock()
+	empty := len(activeSpotPrices.Prices) == 0
+	activeSpotPrices.RUnlock()
+	if empty {
+		return
+	}
+	if err := WriteSpotPriceHistory(pricingDirPath, activeSpotPrices); err != nil {
+		log.Errorln("Unable to store spot price history", err)
+	}
+}
+
+// ImportSpotPriceHistory adds the prices of a saved DescribeSpotPriceHistory response, such as the
+// output of aws ec2 describe-spot-price-history, to h and returns how many were new
+func ImportSpotPriceHistory(r io.Reader, h *SpotPriceHistory) (int, error) {
+	var out ec2.DescribeSpotPriceHistoryOutput
+	if err := json.NewDecoder(r).Decode(&out); err != nil {
+		return 0, err
+	}
+	return h.addSpotPrices(out.SpotPriceHistory), nil
+}
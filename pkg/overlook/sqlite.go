@@ -0,0 +1,397 @@
+package overlook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Registers the sqlite3 database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// StoreTypeSQLite keeps snapshots as rows of an embedded SQLite database
+const StoreTypeSQLite = "sqlite"
+
+// sqliteSchema has a row per snapshot of a sample, the columns can be queried directly and the
+// snapshot column holds the whole BillingSnapshot as JSON. hour is the hour of the day the sample was
+// taken in and interval_seconds how often samples were being taken. resource_id is the key of the
+// snapshot, which includes its account.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS samples (
+	day TEXT NOT NULL,
+	hour INTEGER NOT NULL,
+	sample_time INTEGER NOT NULL,
+	interval_seconds INTEGER NOT NULL DEFAULT 3600,
+	provider TEXT NOT NULL,
+	account TEXT NOT NULL,
+	region TEXT NOT NULL,
+	resource_id TEXT NOT NULL,
+	resource_type TEXT NOT NULL,
+	instance_type TEXT NOT NULL,
+	state TEXT NOT NULL,
+	tags TEXT NOT NULL,
+	cost_per_hour REAL NOT NULL,
+	snapshot TEXT NOT NULL,
+	PRIMARY KEY (day, sample_time, region, resource_id)
+);
+CREATE INDEX IF NOT EXISTS samples_sample_time ON samples (sample_time);
+CREATE INDEX IF NOT EXISTS samples_account ON samples (account, sample_time);
+CREATE TABLE IF NOT EXISTS failed_regions (
+	day TEXT NOT NULL,
+	hour INTEGER NOT NULL,
+	sample_time INTEGER NOT NULL,
+	provider TEXT NOT NULL,
+	account TEXT NOT NULL,
+	region TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS failed_regions_day ON failed_regions (day, hour);
+`
+
+// sqliteUpgradeSamples moves the samples of a database created when samples were keyed by hour to a
+// table keyed by sample time, every one of those samples was taken an hour apart
+const sqliteUpgradeSamples = `
+DROP INDEX IF EXISTS samples_sample_time;
+DROP INDEX IF EXISTS samples_account;
+ALTER TABLE samples RENAME TO samples_by_hour;
+` + sqliteSchema + `
+INSERT INTO samples (day, hour, sample_time, interval_seconds, provider, account, region, resource_id,
+	resource_type, instance_type, state, tags, cost_per_hour, snapshot)
+	SELECT day, hour, sample_time, 3600, provider, account, region, resource_id,
+	resource_type, instance_type, state, tags, cost_per_hour, snapshot FROM samples_by_hour;
+DROP TABLE samples_by_hour;
+`
+
+// GetSQLiteDataLocation returns where the SQLite snapshot database will exist
+func GetSQLiteDataLocation() string {
+	s, _ := filepath.Abs(filepath.Join(".", "billing.db"))
+	return s
+}
+
+// SQLiteSnapshotStore keeps snapshots as rows of a SQLite database
+type SQLiteSnapshotStore struct {
+	DB *sql.DB
+	// Path is the database file, locked through a file alongside it
+	Path string
+}
+
+// OpenSQLiteSnapshotStore opens the SQLite database at path, creating its tables if needed
+func OpenSQLiteSnapshotStore(path string) (*SQLiteSnapshotStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := upgradeSQLiteSchema(db); err != nil {
+		CheckClose(db)
+		return nil, fmt.Errorf("unable to upgrade tables in %s: %v", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		CheckClose(db)
+		return nil, fmt.Errorf("unable to create tables in %s: %v", path, err)
+	}
+	return &SQLiteSnapshotStore{DB: db, Path: path}, nil
+}
+
+// upgradeSQLiteSchema upgrades a samples table without the interval_seconds column, nothing is done
+// for a new database
+func upgradeSQLiteSchema(db *sql.DB) (err error) {
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'samples'`).Scan(&tables); err != nil {
+		return err
+	}
+	if tables == 0 {
+		return nil
+	}
+	var columns int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('samples') WHERE name = 'interval_seconds'`).Scan(&columns); err != nil {
+		return err
+	}
+	if columns > 0 {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if _, err = tx.Exec(sqliteUpgradeSamples); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the database
+func (s *SQLiteSnapshotStore) Close() error {
+	return s.DB.Close()
+}
+
+// Days returns the days with a sample, most recent first
+func (s *SQLiteSnapshotStore) Days() ([]string, error) {
+	rows, err := s.DB.Query(`SELECT day FROM (SELECT day, sample_time FROM samples UNION ALL SELECT day, sample_time FROM failed_regions)
+		GROUP BY day ORDER BY MIN(sample_time) DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer CheckClose(rows)
+	days := make([]string, 0)
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// Read returns the samples of a day
+func (s *SQLiteSnapshotStore) Read(day string) (BillingDailyEntry, error) {
+	_, byDay, err := s.query(`WHERE day = ?`, []interface{}{day})
+	if err != nil {
+		return nil, err
+	}
+	dailyEntry, ok := byDay[day]
+	if !ok {
+		dailyEntry = BillingDailyEntry{day: make(BillingTimeEntry)}
+	}
+	// A day that is read whole also has the samples where every region failed
+	if err := s.addFailedRegions(day, dailyEntry, true); err != nil {
+		return nil, err
+	}
+	if len(dailyEntry[day]) == 0 {
+		return make(BillingDailyEntry), nil
+	}
+	return dailyEntry, nil
+}
+
+// Query returns the samples matching filter, a BillingDailyEntry per day, most recent first
+func (s *SQLiteSnapshotStore) Query(filter SnapshotFilter) ([]BillingDailyEntry, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "sample_time >= ?")
+		args = append(args, dateOf(filter.From).Unix())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "sample_time < ?")
+		args = append(args, dateOf(filter.To).AddDate(0, 0, 1).Unix())
+	}
+	for column, value := range map[string]string{
+		"account":       filter.Account,
+		"region":        filter.Region,
+		"instance_type": filter.InstanceType,
+	} {
+		if value != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, value)
+		}
+	}
+	if filter.State != "" {
+		// Snapshots taken before the state was recorded are running
+		conditions = append(conditions, "(state = ? OR (state = '' AND ? = '"+InstanceStateRunning+"'))")
+		args = append(args, filter.State, filter.State)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	entries, byDay, err := s.query(where, args)
+	if err != nil {
+		return nil, err
+	}
+	for day, dailyEntry := range byDay {
+		if err := s.addFailedRegions(day, dailyEntry, false); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// query returns the samples selected by where grouped into a BillingDailyEntry per day, most recent
+// first, and by the name of the day
+func (s *SQLiteSnapshotStore) query(where string, args []interface{}) ([]BillingDailyEntry, map[string]BillingDailyEntry, error) {
+	rows, err := s.DB.Query(`SELECT day, sample_time, interval_seconds, region, snapshot FROM samples `+where+` ORDER BY sample_time DESC`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer CheckClose(rows)
+	entries := make([]BillingDailyEntry, 0)
+	byDay := make(map[string]BillingDailyEntry)
+	for rows.Next() {
+		var day, region, snapshot string
+		var at, interval int64
+		if err := rows.Scan(&day, &at, &interval, &region, &snapshot); err != nil {
+			return nil, nil, err
+		}
+		key := sampleKey(time.Unix(at, 0))
+		var b BillingSnapshot
+		if err := json.Unmarshal([]byte(snapshot), &b); err != nil {
+			return nil, nil, fmt.Errorf("unable to parse snapshot of %s on %s at %s: %v", region, day, key, err)
+		}
+		dailyEntry, ok := byDay[day]
+		if !ok {
+			dailyEntry = BillingDailyEntry{day: make(BillingTimeEntry)}
+			byDay[day] = dailyEntry
+			entries = append(entries, dailyEntry)
+		}
+		sample, ok := dailyEntry[day][key]
+		if !ok {
+			sample.Regions = make(BillingRegionEntry)
+			sample.Interval = time.Duration(interval) * time.Second
+		}
+		if _, ok := sample.Regions[region]; !ok {
+			sample.Regions[region] = make(BillingInstancesEntry)
+		}
+		sample.Regions[region][b.Key()] = b
+		dailyEntry[day][key] = sample
+	}
+	return entries, byDay, rows.Err()
+}
+
+// addFailedRegions records the regions that failed on day in the samples of dailyEntry, adding the
+// samples without any snapshot when addSamples is set
+func (s *SQLiteSnapshotStore) addFailedRegions(day string, dailyEntry BillingDailyEntry, addSamples bool) error {
+	rows, err := s.DB.Query(`SELECT sample_time, provider, account, region, status, error FROM failed_regions WHERE day = ?`, day)
+	if err != nil {
+		return err
+	}
+	defer CheckClose(rows)
+	for rows.Next() {
+		var at int64
+		var f FailedRegion
+		if err := rows.Scan(&at, &f.Provider, &f.AccountID, &f.Region, &f.Status, &f.Error); err != nil {
+			return err
+		}
+		key := sampleKey(time.Unix(at, 0))
+		sample, ok := dailyEntry[day][key]
+		if !ok {
+			if !addSamples {
+				continue
+			}
+			sample.Regions = make(BillingRegionEntry)
+		}
+		sample.FailedRegions = append(sample.FailedRegions, f)
+		dailyEntry[day][key] = sample
+	}
+	return rows.Err()
+}
+
+// Write replaces the samples of a day
+func (s *SQLiteSnapshotStore) Write(day string, dailyEntry BillingDailyEntry) (err error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if _, err = tx.Exec(`DELETE FROM samples WHERE day = ?`, day); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM failed_regions WHERE day = ?`, day); err != nil {
+		return err
+	}
+	for key, sample := range dailyEntry[day] {
+		if err = insertSample(tx, day, key, sample); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// WriteSample replaces a single sample of a day, the rows of its snapshots are inserted or replaced
+// by their key and the other samples of the day are left as they are
+func (s *SQLiteSnapshotStore) WriteSample(day string, key string, sample BillingSampleEntry) (err error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	// The failed regions of the sample are replaced with those it has now
+	if _, err = tx.Exec(`DELETE FROM failed_regions WHERE day = ? AND sample_time = ?`, day, sampleTime(day, key).Unix()); err != nil {
+		return err
+	}
+	if err = insertSample(tx, day, key, sample); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertSample inserts the snapshots and failed regions of the sample of day keyed by key, replacing
+// the rows of snapshots already stored
+func insertSample(tx *sql.Tx, day string, key string, sample BillingSampleEntry) error {
+	insertSnapshot, err := tx.Prepare(`INSERT OR REPLACE INTO samples (day, hour, sample_time, interval_seconds, provider, account, region,
+		resource_id, resource_type, instance_type, state, tags, cost_per_hour, snapshot) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer CheckClose(insertSnapshot)
+	insertFailed, err := tx.Prepare(`INSERT INTO failed_regions (day, hour, sample_time, provider, account, region, status, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer CheckClose(insertFailed)
+
+	t := sampleTime(day, key)
+	at := t.Unix()
+	interval := int64(sample.SampleInterval() / time.Second)
+	for region, regionEntry := range sample.Regions {
+		for _, b := range regionEntry {
+			snapshot, err := json.Marshal(b)
+			if err != nil {
+				return err
+			}
+			_, err = insertSnapshot.Exec(day, t.Hour(), at, interval, b.SnapshotProvider(), b.AccountID, region, b.Key(),
+				b.ResourceType, b.InstanceType, b.State, b.Tags, b.CostPerHour, string(snapshot))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range sample.FailedRegions {
+		if _, err := insertFailed.Exec(day, t.Hour(), at, f.Provider, f.AccountID, f.Region, f.Status, f.Error); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock takes an advisory lock on a file alongside the database, held by one process at a time
+func (s *SQLiteSnapshotStore) Lock() (func() error, error) {
+	return lockFile(s.Path + ".lock")
+}
+
+// Delete removes the samples of a day
+func (s *SQLiteSnapshotStore) Delete(day string) (err error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if _, err = tx.Exec(`DELETE FROM samples WHERE day = ?`, day); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM failed_regions WHERE day = ?`, day); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,115 @@
+package overlook
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestSQLiteStore(t *testing.T) *SQLiteSnapshotStore {
+	store, err := OpenSQLiteSnapshotStore(filepath.Join(t.TempDir(), "billing.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CheckClose(store) })
+	return store
+}
+
+func TestSQLiteStoreBillingSnapshotsAddsSample(t *testing.T) {
+	store := openTestSQLiteStore(t)
+	// testDailyEntry has a sample an hour into the day
+	first := time.Date(2021, 3, 1, 1, 0, 0, 0, time.UTC)
+	dailyEntry := testDailyEntry("2021-03-01", "i-1")
+	for key, sample := range dailyEntry["2021-03-01"] {
+		sample.FailedRegions = []FailedRegion{{Provider: ProviderAWS, AccountID: "1", Region: "eu-west-1", Status: RegionStatusFailed}}
+		dailyEntry["2021-03-01"][key] = sample
+	}
+	if err := store.Write("2021-03-01", dailyEntry); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(now func() time.Time) { Now = now }(Now)
+	second := first.Add(time.Hour)
+	Now = func() time.Time { return second }
+	regionInfo := []RegionInfo{{
+		Provider:   ProviderAWS,
+		AccountID:  "1",
+		RegionName: "us-east-1",
+		Status:     RegionStatusOK,
+		BillingSnapshots: []BillingSnapshot{
+			{ID: "i-1", InstanceType: "m5.large", Region: "us-east-1", State: InstanceStateStopped},
+			{ID: "i-2", InstanceType: "m5.large", Region: "us-east-1", State: InstanceStateRunning},
+		},
+	}}
+	if err := StoreBillingSnapshots(regionInfo, store, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// Storing the same sample again replaces its rows rather than adding to them
+	if err := StoreBillingSnapshots(regionInfo, store, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := store.Read("2021-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := read["2021-03-01"]
+	if len(samples) != 2 {
+		t.Fatalf("expected both samples, got %v", samples)
+	}
+	kept := samples[sampleKey(first)]
+	if len(kept.Regions["us-east-1"]) != 1 || len(kept.FailedRegions) != 1 {
+		t.Errorf("expected the earlier sample to be kept as it was, got %+v", kept)
+	}
+	added := samples[sampleKey(second)]
+	if len(added.Regions["us-east-1"]) != 2 || len(added.FailedRegions) != 0 {
+		t.Errorf("expected the new sample, got %+v", added)
+	}
+	if b := added.Regions["us-east-1"]["i-1"]; b.PreviousState != InstanceStateRunning {
+		t.Errorf("expected the stop of i-1 to be recorded, got %+v", b)
+	}
+
+	var rows int
+	if err := store.DB.QueryRow(`SELECT COUNT(*) FROM samples`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 3 {
+		t.Errorf("expected a row per snapshot of each sample, got %d", rows)
+	}
+}
+
+func TestSQLiteSnapshotStoreLock(t *testing.T) {
+	store := openTestSQLiteStore(t)
+	unlock, err := store.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan func() error)
+	go func() {
+		unlock, err := store.Lock()
+		if err != nil {
+			t.Error(err)
+		}
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the store was locked twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case unlock := <-locked:
+		if unlock != nil {
+			if err := unlock(); err != nil {
+				t.Error(err)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the store was not locked once it was unlocked")
+	}
+}
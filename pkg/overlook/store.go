@@ -0,0 +1,269 @@
+package overlook
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	log "github.com/sirupsen/logrus"
+)
+
+// Backends of the snapshot store
+const (
+	StoreTypeFile = "file"
+	StoreTypeS3   = "s3"
+)
+
+// SnapshotStore keeps the daily snapshots, a day is named by its date as in BillingDailyEntry
+type SnapshotStore interface {
+	// Days returns the stored days, most recent first
+	Days() ([]string, error)
+	// Read returns the snapshots of a day, empty when the day is not stored
+	Read(day string) (BillingDailyEntry, error)
+	// Write replaces the snapshots of a day
+	Write(day string, dailyEntry BillingDailyEntry) error
+	// Delete removes the snapshots of a day
+	Delete(day string) error
+}
+
+// SnapshotLocker is implemented by snapshot stores that can keep other processes from changing them
+// in between reading and writing a day
+type SnapshotLocker interface {
+	// Lock blocks until the store is locked and returns the function unlocking it
+	Lock() (func() error, error)
+}
+
+// SampleWriter is implemented by snapshot stores that can write a single sample of a day without
+// rewriting the others
+type SampleWriter interface {
+	// WriteSample replaces the sample of a day keyed by key
+	WriteSample(day string, key string, sample BillingSampleEntry) error
+}
+
+// lockStore locks store when it supports locking, the returned function unlocks it
+func lockStore(store SnapshotStore) (func(), error) {
+	locker, ok := store.(SnapshotLocker)
+	if !ok {
+		return func() {}, nil
+	}
+	unlock, err := locker.Lock()
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock snapshot store: %v", err)
+	}
+	return func() {
+		if err := unlock(); err != nil {
+			log.Errorln("Unable to unlock snapshot store", err)
+		}
+	}, nil
+}
+
+// StoreConfig selects and configures the snapshot store, read from the "store" section of the config file
+type StoreConfig struct {
+	Type string `mapstructure:"type"`
+	// Dir is where the file store keeps its files, ./billing by default
+	Dir string `mapstructure:"dir"`
+	// Bucket and Prefix are where the S3 store keeps its objects, Endpoint points at an
+	// S3-compatible server such as MinIO instead of AWS
+	Bucket   string `mapstructure:"bucket"`
+	Prefix   string `mapstructure:"prefix"`
+	Region   string `mapstructure:"region"`
+	Endpoint string `mapstructure:"endpoint"`
+	// Path is the database file of the SQLite store, ./billing.db by default
+	Path string `mapstructure:"path"`
+	// Nested keeps the days of the file and S3 stores as YYYY/MM/DD.json rather than YYYY-MM-DD.json
+	Nested bool `mapstructure:"nested"`
+}
+
+// FileSnapshotStore keeps a JSON file per day in a local directory
+type FileSnapshotStore struct {
+	Dir    string
+	Nested bool
+}
+
+// NewFileSnapshotStore returns a store of the snapshot files in dir
+func NewFileSnapshotStore(dir string) *FileSnapshotStore {
+	return &FileSnapshotStore{Dir: dir}
+}
+
+// Days returns the days with a snapshot file, flat or nested, most recent first
+func (s *FileSnapshotStore) Days() ([]string, error) {
+	if _, err := os.Stat(s.Dir); err != nil {
+		return nil, fmt.Errorf("unable to list billing directory %s: %v", s.Dir, err)
+	}
+	days := make([]string, 0)
+	err := filepath.Walk(s.Dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.Dir, name)
+		if err != nil {
+			return err
+		}
+		if day, ok := dayFromFileName(filepath.ToSlash(rel)); ok {
+			days = append(days, day)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list billing directory %s: %v", s.Dir, err)
+	}
+	return sortDays(days), nil
+}
+
+// fileName returns the file of a day, the file in the other of the flat and nested layouts is
+// used when it is the only one that exists
+func (s *FileSnapshotStore) fileName(day string) string {
+	name := filepath.Join(s.Dir, filepath.FromSlash(dayFileName(day, s.Nested)))
+	if other := filepath.Join(s.Dir, filepath.FromSlash(dayFileName(day, !s.Nested))); !Exists(name) && Exists(other) {
+		return other
+	}
+	return name
+}
+
+// Read returns the snapshots in the file of a day
+func (s *FileSnapshotStore) Read(day string) (BillingDailyEntry, error) {
+	return ReadSnapshotInfo(s.fileName(day))
+}
+
+// Write replaces the file of a day
+func (s *FileSnapshotStore) Write(day string, dailyEntry BillingDailyEntry) error {
+	name := s.fileName(day)
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return err
+	}
+	return writeSnapshotInfo(name, dailyEntry)
+}
+
+// Delete removes the file of a day
+func (s *FileSnapshotStore) Delete(day string) error {
+	for _, nested := range []bool{false, true} {
+		err := os.Remove(filepath.Join(s.Dir, filepath.FromSlash(dayFileName(day, nested))))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock takes an advisory lock on the directory, held by one process at a time
+func (s *FileSnapshotStore) Lock() (func() error, error) {
+	if err := s.createDir(); err != nil {
+		return nil, err
+	}
+	return lockFile(filepath.Join(s.Dir, ".lock"))
+}
+
+func (s *FileSnapshotStore) createDir() error {
+	if _, err := os.Stat(s.Dir); os.IsNotExist(err) {
+		return os.MkdirAll(s.Dir, os.ModePerm)
+	}
+	return nil
+}
+
+// S3SnapshotStore keeps an object per day in an S3 bucket, under Prefix, laid out as a snapshot file
+type S3SnapshotStore struct {
+	Client s3iface.S3API
+	Bucket string
+	Prefix string
+	Nested bool
+}
+
+// NewS3SnapshotStore returns a store of the snapshot objects under prefix in bucket
+func NewS3SnapshotStore(client s3iface.S3API, bucket string, prefix string) *S3SnapshotStore {
+	return &S3SnapshotStore{Client: client, Bucket: bucket, Prefix: strings.Trim(prefix, "/")}
+}
+
+func (s *S3SnapshotStore) key(day string, nested bool) string {
+	return path.Join(s.Prefix, dayFileName(day, nested))
+}
+
+// Days returns the days with a snapshot object, flat or nested, most recent first
+func (s *S3SnapshotStore) Days() ([]string, error) {
+	prefix := ""
+	if s.Prefix != "" {
+		prefix = s.Prefix + "/"
+	}
+	days := make([]string, 0)
+	err := s.Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{Bucket: aws.String(s.Bucket), Prefix: aws.String(prefix)},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, o := range page.Contents {
+				if day, ok := dayFromFileName(strings.TrimPrefix(aws.StringValue(o.Key), prefix)); ok {
+					days = append(days, day)
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list s3://%s/%s: %v", s.Bucket, prefix, err)
+	}
+	return sortDays(days), nil
+}
+
+// Read returns the snapshots in the object of a day, the object in the other of the flat and nested
+// layouts is read when the day has none in its own
+func (s *S3SnapshotStore) Read(day string) (BillingDailyEntry, error) {
+	dailyEntry, found, err := s.read(s.key(day, s.Nested))
+	if err != nil || found {
+		return dailyEntry, err
+	}
+	dailyEntry, _, err = s.read(s.key(day, !s.Nested))
+	return dailyEntry, err
+}
+
+func (s *S3SnapshotStore) read(key string) (BillingDailyEntry, bool, error) {
+	out, err := s.Client.GetObject(&s3.GetObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return make(BillingDailyEntry), false, nil
+		}
+		return nil, false, fmt.Errorf("unable to read s3://%s/%s: %v", s.Bucket, key, err)
+	}
+	defer CheckClose(out.Body)
+	byteValue, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	dailyEntry, err := decodeSnapshot(fmt.Sprintf("s3://%s/%s", s.Bucket, key), byteValue)
+	if err != nil {
+		return nil, false, err
+	}
+	return dailyEntry, true, nil
+}
+
+// Write replaces the object of a day, in the same layout and with the same checksum as a snapshot file
+func (s *S3SnapshotStore) Write(day string, dailyEntry BillingDailyEntry) error {
+	data, err := encodeSnapshot(dailyEntry)
+	if err != nil {
+		return err
+	}
+	key := s.key(day, s.Nested)
+	_, err = s.Client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write s3://%s/%s: %v", s.Bucket, key, err)
+	}
+	return nil
+}
+
+// Delete removes the object of a day
+func (s *S3SnapshotStore) Delete(day string) error {
+	for _, nested := range []bool{false, true} {
+		key := s.key(day, nested)
+		if _, err := s.Client.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)}); err != nil {
+			return fmt.Errorf("unable to delete s3://%s/%s: %v", s.Bucket, key, err)
+		}
+	}
+	return nil
+}
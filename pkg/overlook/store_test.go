@@ -0,0 +1,315 @@
+package overlook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// fakeS3 keeps the objects of a single bucket in memory and lists them two to a page
+type fakeS3 struct {
+	s3iface.S3API
+	bucket  string
+	objects map[string][]byte
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{bucket: bucket, objects: make(map[string][]byte)}
+}
+
+func (f *fakeS3) checkBucket(bucket *string) error {
+	if aws.StringValue(bucket) != f.bucket {
+		return awserr.New(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist", nil)
+	}
+	return nil
+}
+
+func (f *fakeS3) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	if err := f.checkBucket(input.Bucket); err != nil {
+		return err
+	}
+	keys := make([]string, 0)
+	for key := range f.objects {
+		if strings.HasPrefix(key, aws.StringValue(input.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for start := 0; start == 0 || start < len(keys); start += 2 {
+		end := start + 2
+		if end > len(keys) {
+			end = len(keys)
+		}
+		page := &s3.ListObjectsV2Output{}
+		for _, key := range keys[start:end] {
+			page.Contents = append(page.Contents, &s3.Object{Key: aws.String(key)})
+		}
+		if !fn(page, end == len(keys)) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if err := f.checkBucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	data, ok := f.objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist.", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f *fakeS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if err := f.checkBucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[aws.StringValue(input.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+// DeleteObject succeeds whether or not the key exists, as S3 does
+func (f *fakeS3) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	if err := f.checkBucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	delete(f.objects, aws.StringValue(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func testDailyEntry(day string, id string) BillingDailyEntry {
+	t, _ := time.Parse(DayLayout, day)
+	snapshot := BillingSnapshot{ID: id, InstanceType: "m5.large", Region: "us-east-1", State: InstanceStateRunning, CostPerHour: 0.096}
+	return BillingDailyEntry{day: BillingTimeEntry{
+		sampleKey(t.Add(time.Hour)): BillingSampleEntry{
+			Regions:  BillingRegionEntry{"us-east-1": BillingInstancesEntry{id: snapshot}},
+			Interval: time.Hour,
+		},
+	}}
+}
+
+func TestS3SnapshotStoreDays(t *testing.T) {
+	client := newFakeS3("bucket")
+	for _, key := range []string{
+		"overlook/2021-03-01.json",
+		"overlook/2021/03/02.json",
+		"overlook/03-03-2021.json",
+		// Duplicates in both layouts are listed once
+		"overlook/2021-03-02.json",
+		"overlook/.lock.json",
+		"overlook/notes.txt",
+		"overlook/reports/summary.json",
+		"other/2021-03-04.json",
+		"2021-03-05.json",
+	} {
+		client.objects[key] = []byte{}
+	}
+
+	store := NewS3SnapshotStore(client, "bucket", "/overlook/")
+	days, err := store.Days()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"03-03-2021", "2021-03-02", "2021-03-01"}; !reflect.DeepEqual(days, expected) {
+		t.Errorf("expected days %v, got %v", expected, days)
+	}
+
+	store = NewS3SnapshotStore(client, "missing", "overlook")
+	if _, err := store.Days(); err == nil {
+		t.Error("expected an error listing a missing bucket")
+	}
+}
+
+func TestS3SnapshotStoreReadWrite(t *testing.T) {
+	client := newFakeS3("bucket")
+	store := NewS3SnapshotStore(client, "bucket", "overlook")
+
+	dailyEntry, err := store.Read("2021-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dailyEntry) != 0 {
+		t.Errorf("expected a missing day to be empty, got %v", dailyEntry)
+	}
+
+	written := testDailyEntry("2021-03-01", "i-1")
+	if err := store.Write("2021-03-01", written); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.objects["overlook/2021-03-01.json"]; !ok {
+		t.Fatalf("expected a flat key, got %v", client.objects)
+	}
+	dailyEntry, err = store.Read("2021-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dailyEntry, written) {
+		t.Errorf("expected %v, got %v", written, dailyEntry)
+	}
+
+	// A nested store writes nested keys and still reads the days stored flat
+	store.Nested = true
+	if err := store.Write("2021-03-02", testDailyEntry("2021-03-02", "i-2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.objects["overlook/2021/03/02.json"]; !ok {
+		t.Fatalf("expected a nested key, got %v", client.objects)
+	}
+	dailyEntry, err = store.Read("2021-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dailyEntry, written) {
+		t.Errorf("expected the flat day from a nested store, got %v", dailyEntry)
+	}
+
+	// Objects written before the checksum hold the snapshots alone
+	client.objects["overlook/2021-03-03.json"] = []byte(`{"2021-03-03": {}}`)
+	dailyEntry, err = store.Read("2021-03-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dailyEntry["2021-03-03"]; !ok {
+		t.Errorf("expected an object without a checksum to be read, got %v", dailyEntry)
+	}
+}
+
+func TestS3SnapshotStoreCorruptObject(t *testing.T) {
+	client := newFakeS3("bucket")
+	store := NewS3SnapshotStore(client, "bucket", "")
+	if err := store.Write("2021-03-01", testDailyEntry("2021-03-01", "i-1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Write("2021-03-02", testDailyEntry("2021-03-02", "i-2")); err != nil {
+		t.Fatal(err)
+	}
+	client.objects["2021-03-01.json"] = bytes.Replace(client.objects["2021-03-01.json"], []byte("i-1"), []byte("i-9"), -1)
+	client.objects["2021-03-03.json"] = []byte(`{"Checksum": "sha256:`)
+
+	for _, day := range []string{"2021-03-01", "2021-03-03"} {
+		_, err := store.Read(day)
+		if _, ok := err.(*CorruptSnapshotError); !ok {
+			t.Errorf("expected %s to be corrupted, got %v", day, err)
+		}
+	}
+
+	entries, err := QuerySnapshots(store, SnapshotFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected the corrupted days to be skipped, got %v", entries)
+	}
+	if _, ok := entries[0]["2021-03-02"]; !ok {
+		t.Errorf("expected the intact day, got %v", entries[0])
+	}
+}
+
+func TestFileSnapshotStoreCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileSnapshotStore(dir)
+	for _, day := range []string{"2021-03-01", "2021-03-02"} {
+		if err := store.Write(day, testDailyEntry(day, "i-1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Written before files carried a checksum
+	legacy, err := json.Marshal(testDailyEntry("2021-03-03", "i-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "2021-03-03.json"), legacy, 0660); err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := ioutil.ReadFile(filepath.Join(dir, "2021-03-01.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "2021-03-01.json"), bytes.Replace(tampered, []byte("i-1"), []byte("i-9"), -1), 0660); err != nil {
+		t.Fatal(err)
+	}
+	truncated, err := ioutil.ReadFile(filepath.Join(dir, "2021-03-02.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "2021-03-02.json"), truncated[:len(truncated)/2], 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	for day, reason := range map[string]string{"2021-03-01": "checksum does not match", "2021-03-02": "unexpected end of JSON input"} {
+		_, err := store.Read(day)
+		if corrupt, ok := err.(*CorruptSnapshotError); !ok || corrupt.Reason != reason {
+			t.Errorf("expected %s to be corrupted as %q, got %v", day, reason, err)
+		}
+	}
+	dailyEntry, err := store.Read("2021-03-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dailyEntry, testDailyEntry("2021-03-03", "i-1")) {
+		t.Errorf("expected the legacy file to be read, got %v", dailyEntry)
+	}
+
+	entries, err := QuerySnapshots(store, SnapshotFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected the corrupted days to be skipped, got %v", entries)
+	}
+	if _, ok := entries[0]["2021-03-03"]; !ok {
+		t.Errorf("expected the legacy day, got %v", entries[0])
+	}
+
+	// Samples of a corrupted day are refused rather than replacing the samples already in it
+	defer func(now func() time.Time) { Now = now }(Now)
+	Now = func() time.Time { return time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC) }
+	err = StoreBillingSnapshots(testAccountsRegionInfo(InstanceStateRunning, InstanceStateRunning), store, time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "moved aside") {
+		t.Errorf("expected the corrupted day to be refused with how to recover, got %v", err)
+	}
+	if written, _ := ioutil.ReadFile(filepath.Join(dir, "2021-03-01.json")); bytes.Contains(written, []byte("111111111111")) {
+		t.Error("expected the corrupted day to be left alone")
+	}
+}
+
+func TestS3SnapshotStoreDelete(t *testing.T) {
+	client := newFakeS3("bucket")
+	store := NewS3SnapshotStore(client, "bucket", "overlook")
+	client.objects["overlook/2021-03-01.json"] = []byte{}
+	client.objects["overlook/2021/03/01.json"] = []byte{}
+	client.objects["overlook/2021-03-02.json"] = []byte{}
+
+	if err := store.Delete("2021-03-01"); err != nil {
+		t.Fatal(err)
+	}
+	// Deleting a day that is not stored is not an error
+	if err := store.Delete("2021-03-09"); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0)
+	for key := range client.objects {
+		keys = append(keys, key)
+	}
+	if fmt.Sprint(keys) != "[overlook/2021-03-02.json]" {
+		t.Errorf("expected both layouts of the day to be deleted, left %v", keys)
+	}
+}
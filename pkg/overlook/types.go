@@ -1,80 +1,412 @@
 package overlook
 
 import (
+	"encoding/json"
 	"fmt"
-	"github.com/aws/aws-sdk-go/service/ec2"
 	"sort"
+	"strings"
+	"time"
 )
 
-// RegionInfo captures instance info across a region
+// Collection status of a region
+const (
+	RegionStatusOK      = "ok"
+	RegionStatusPartial = "partial"
+	RegionStatusFailed  = "failed"
+)
+
+// AllRegions is the region recorded for an account that failed before its regions were listed
+const AllRegions = "*"
+
+// RegionInfo captures instance and volume info across a region of an account
 type RegionInfo struct {
-	Instances        []InstanceInfo
-	RegionName       string
-	Cost             float64
-	TypeSummary      map[string]InstanceTypeSummary
-	BillingSnapshots []BillingSnapshot
+	Provider          string
+	AccountID         string
+	Status            string
+	Err               error
+	Stats             CallStats
+	Instances         []InstanceInfo
+	Volumes           []VolumeInfo
+	Network           []NetworkResourceInfo
+	DBInstances       []DBInstanceInfo
+	RegionName        string
+	Cost              float64
+	VolumeCost        float64
+	NetworkCost       float64
+	DBCost            float64
+	TypeSummary       map[string]InstanceTypeSummary
+	VolumeTypeSummary map[string]VolumeTypeSummary
+	NetworkSummary    map[string]NetworkTypeSummary
+	DBSummary         map[string]DBInstanceClassSummary
+	BillingSnapshots  []BillingSnapshot
 }
 
-// InstanceInfo captures info we care most about
+// InstanceInfo captures info we care most about for a compute instance of any provider,
+// State is one of the shared instance states
 type InstanceInfo struct {
-	Instance         *ec2.Instance
+	ID               string
+	Provider         string
 	HoursUp          float64
 	Cost             float64
 	State            string
 	Tags             string
 	InstanceType     string
+	Platform         string
+	PlatformDetails  string
+	UsageOperation   string
+	Tenancy          string
+	Lifecycle        string
 	AvailabilityZone string
 	Arn              string
 	Region           string
 }
 
+// PriceKey returns what the instance is priced by
+func (i InstanceInfo) PriceKey() PriceKey {
+	return PriceKey{
+		Region:          i.Region,
+		InstanceType:    i.InstanceType,
+		OperatingSystem: PricingOperatingSystem(i.Platform, i.PlatformDetails, i.UsageOperation),
+		Tenancy:         PricingTenancy(i.Tenancy),
+	}
+}
+
+// VolumeInfo captures info we care most about for an EBS volume
+type VolumeInfo struct {
+	ID               string
+	VolumeType       string
+	SizeGB           int64
+	Iops             int64
+	State            string
+	AttachedTo       string
+	HoursUp          float64
+	Tags             string
+	AvailabilityZone string
+	Region           string
+}
+
+// Attached reports whether the volume is attached to an instance
+func (v VolumeInfo) Attached() bool {
+	return v.AttachedTo != ""
+}
+
 // InstanceTypeSummary tracks aggregate info about a specific instance type
 type InstanceTypeSummary struct {
 	InstanceType      string
 	NumberOfInstances int
+	NumberNotRunning  int
+	NumberUnpriced    int
+	TotalHours        float64
+	Cost              float64
+}
+
+// NetworkResourceInfo captures info about an Elastic IP, NAT gateway or load balancer
+type NetworkResourceInfo struct {
+	ID               string
+	ResourceType     string
+	LoadBalancerType string
+	State            string
+	Idle             bool
+	HoursUp          float64
+	Tags             string
+	Region           string
+}
+
+// Category is the resource type, qualified by the load balancer type for load balancers
+func (n NetworkResourceInfo) Category() string {
+	return networkCategory(n.ResourceType, n.LoadBalancerType)
+}
+
+// NetworkTypeSummary tracks aggregate info about a category of networking resources
+type NetworkTypeSummary struct {
+	Category        string
+	Number          int
+	NumberIdle      int
+	NumberUnpriced  int
+	CostPerHour     float64
+	IdleCostPerHour float64
+}
+
+// DBInstanceInfo captures info we care most about for an RDS DB instance
+type DBInstanceInfo struct {
+	ID               string
+	Arn              string
+	DBInstanceClass  string
+	Engine           string
+	MultiAZ          bool
+	AllocatedStorage int64
+	StorageType      string
+	State            string
+	HoursUp          float64
+	Tags             string
+	AvailabilityZone string
+	Region           string
+}
+
+// DBInstanceClassSummary tracks aggregate info about a specific DB instance class
+type DBInstanceClassSummary struct {
+	DBInstanceClass   string
+	NumberOfInstances int
+	NumberUnpriced    int
 	TotalHours        float64
 	Cost              float64
 }
 
+// VolumeTypeSummary tracks aggregate info about a specific volume type
+type VolumeTypeSummary struct {
+	VolumeType            string
+	NumberOfVolumes       int
+	NumberUnattached      int
+	NumberUnpriced        int
+	TotalGB               int64
+	CostPerHour           float64
+	UnattachedCostPerHour float64
+}
+
 // The layout for the billing snapshot is
 // Each day is a new json file with structure of
 // {"$DATE":
-//  {"$HOUR":
-//   {"$REGION:
-//     { "$INSTANCE_ID_1":  {"$BillingSnapshot"}
-//     { "$INSTANCE_ID_1":  {"$BillingSnapshot"}
+//  {"$SAMPLE_TIME":
+//   {"Regions":
+//    {"$REGION:
+//      { "$INSTANCE_ID_1":  {"$BillingSnapshot"}
+//      { "$INSTANCE_ID_1":  {"$BillingSnapshot"}
+//      { "$VOLUME_ID_1":  {"$BillingSnapshot"}
+//      { "$NAT_GATEWAY_ID_1":  {"$BillingSnapshot"}
+//      { "$DB_INSTANCE_ID_1":  {"$BillingSnapshot"}
+//    },
+//    "FailedRegions": [{"$FailedRegion"}],
+//    "Interval": $NANOSECONDS
 //  }}}
+// Files written before samples were keyed by time have "$HOUR" in place of "$SAMPLE_TIME", and
+// files written before FailedRegions was recorded have the regions directly under "$HOUR"
+
+// BillingDailyEntry, for a given day has all of the billing info organized by sample
+type BillingDailyEntry map[string]BillingTimeEntry
 
-// BillingDailyEntry, for a given day has all of the billing info organized by hour
-type BillingDailyEntry map[string]BillingHourEntry
+// BillingTimeEntry, for a given day, has the samples taken that day keyed by the UTC time they were
+// taken at in RFC3339. Files written before samples were keyed by time have one sample per hour keyed by hour.
+type BillingTimeEntry map[string]BillingSampleEntry
 
-// BillingHourEntry, for a given hour, has all of the billing info organized by region
-type BillingHourEntry map[int]BillingRegionEntry
+// DefaultSampleInterval is how long a sample stands for when its interval was not recorded, samples
+// used to be taken every hour
+const DefaultSampleInterval = time.Hour
+
+// BillingSampleEntry has all of the billing info of a sample organized by region,
+// along with the regions that could not be fully collected
+type BillingSampleEntry struct {
+	Regions       BillingRegionEntry
+	FailedRegions []FailedRegion `json:",omitempty"`
+	// Interval is how often samples were being taken when this one was taken
+	Interval time.Duration `json:",omitempty"`
+}
+
+// SampleInterval returns how long the sample stands for at most, until the next sample is taken
+func (s BillingSampleEntry) SampleInterval() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultSampleInterval
+	}
+	return s.Interval
+}
+
+// UnmarshalJSON reads both samples and the older layout which only had regions
+func (s *BillingSampleEntry) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if _, ok := fields["Regions"]; !ok {
+		s.Regions = make(BillingRegionEntry)
+		return json.Unmarshal(data, &s.Regions)
+	}
+	// sample has the same fields but not the UnmarshalJSON method
+	type sample BillingSampleEntry
+	return json.Unmarshal(data, (*sample)(s))
+}
+
+// FailedRegion records a region of an account that was not fully collected in a sample
+type FailedRegion struct {
+	Provider  string `json:",omitempty"`
+	AccountID string
+	Region    string
+	Status    string
+	Error     string
+}
 
 // BillingRegionEntry, for a given region has all of the billing info organized by instance-id
 type BillingRegionEntry map[string]BillingInstancesEntry
 
-// BillingInstancesEntry, for a given instance-id has the billing information
+// BillingInstancesEntry, for a given BillingSnapshot.Key has the billing information
 type BillingInstancesEntry map[string]BillingSnapshot
 
+// Resource types stored in a BillingSnapshot, snapshots written before
+// ResourceType was introduced are all instances
+const (
+	ResourceTypeInstance     = "instance"
+	ResourceTypeVolume       = "volume"
+	ResourceTypeElasticIP    = "elastic-ip"
+	ResourceTypeNatGateway   = "nat-gateway"
+	ResourceTypeLoadBalancer = "load-balancer"
+	ResourceTypeDBInstance   = "db-instance"
+)
+
 // BillingSnapshot is used to capture time series data of usage
 type BillingSnapshot struct {
 	ID               string
-	InstanceType     string
+	ResourceType     string `json:",omitempty"`
+	Provider         string `json:",omitempty"`
+	AccountID        string `json:",omitempty"`
+	InstanceType     string `json:",omitempty"`
+	Platform         string `json:",omitempty"`
+	UsageOperation   string `json:",omitempty"`
+	Tenancy          string `json:",omitempty"`
+	Lifecycle        string `json:",omitempty"`
+	VolumeType       string `json:",omitempty"`
+	SizeGB           int64  `json:",omitempty"`
+	Iops             int64  `json:",omitempty"`
+	AttachedTo       string `json:",omitempty"`
+	LoadBalancerType string `json:",omitempty"`
+	Idle             bool   `json:",omitempty"`
+	// SizeGB and VolumeType are also the allocated storage and storage type of DB instances
+	DBInstanceClass  string `json:",omitempty"`
+	Engine           string `json:",omitempty"`
+	MultiAZ          bool   `json:",omitempty"`
 	Region           string
 	AvailabilityZone string
 	State            string
+	PreviousState    string `json:",omitempty"`
 	Tags             string
 	HoursUp          float64
 	CostPerHour      float64
 	CurrentCost      float64
+	// Unpriced is set when no price was known for the resource when it was sampled. Unpriced resources are
+	// recorded anyway so they can be priced later, reports keep their hours apart from every cost.
+	Unpriced bool `json:",omitempty"`
+	// PriceListVersion is the version of the price list CostPerHour was taken from
+	PriceListVersion string `json:",omitempty"`
 	Arn              string
 }
 
+// Key identifies the resource of the snapshot within its region, the account is part of it as
+// resource IDs such as load balancer names are reused across accounts. Snapshots written before
+// the account was recorded are keyed by their ID.
+func (b BillingSnapshot) Key() string {
+	if b.AccountID == "" {
+		return b.ID
+	}
+	return b.AccountID + "/" + b.ID
+}
+
+// InstanceState returns the recorded state, snapshots written before every state was
+// recorded only contain running instances
+func (b BillingSnapshot) InstanceState() string {
+	if b.State == "" {
+		return InstanceStateRunning
+	}
+	return b.State
+}
+
+// PriceKey returns what the instance is priced by, Platform holds the platform details of the instance.
+// Snapshots written before the platform was recorded are priced as Linux.
+func (b BillingSnapshot) PriceKey() PriceKey {
+	return PriceKey{
+		Region:          b.Region,
+		InstanceType:    b.InstanceType,
+		OperatingSystem: PricingOperatingSystem("", b.Platform, b.UsageOperation),
+		Tenancy:         PricingTenancy(b.Tenancy),
+	}
+}
+
+// IsSpot reports whether the snapshot describes a spot instance
+func (b BillingSnapshot) IsSpot() bool {
+	return b.Lifecycle == LifecycleSpot
+}
+
+// SnapshotProvider returns the cloud provider the snapshot was collected from
+func (b BillingSnapshot) SnapshotProvider() string {
+	if b.Provider == "" {
+		return ProviderAWS
+	}
+	return b.Provider
+}
+
+// IsRunning reports whether the instance was running, and so billed, when sampled
+func (b BillingSnapshot) IsRunning() bool {
+	return b.InstanceState() == InstanceStateRunning
+}
+
+// IsInstance reports whether the snapshot describes a compute instance
+func (b BillingSnapshot) IsInstance() bool {
+	return b.ResourceType == "" || b.ResourceType == ResourceTypeInstance
+}
+
+// IsVolume reports whether the snapshot describes an EBS volume
+func (b BillingSnapshot) IsVolume() bool {
+	return b.ResourceType == ResourceTypeVolume
+}
+
+// IsDBInstance reports whether the snapshot describes an RDS DB instance
+func (b BillingSnapshot) IsDBInstance() bool {
+	return b.ResourceType == ResourceTypeDBInstance
+}
+
+// IsNetwork reports whether the snapshot describes an Elastic IP, NAT gateway or load balancer
+func (b BillingSnapshot) IsNetwork() bool {
+	switch b.ResourceType {
+	case ResourceTypeElasticIP, ResourceTypeNatGateway, ResourceTypeLoadBalancer:
+		return true
+	}
+	return false
+}
+
+// NetworkCategory is the resource type, qualified by the load balancer type for load balancers
+func (b BillingSnapshot) NetworkCategory() string {
+	return networkCategory(b.ResourceType, b.LoadBalancerType)
+}
+
+// unpricedName names what the resource is priced by in warnings about resources without a price
+func (b BillingSnapshot) unpricedName() string {
+	switch {
+	case b.IsVolume():
+		return "EBS " + b.VolumeType
+	case b.IsNetwork():
+		return b.NetworkCategory()
+	case b.IsDBInstance():
+		return "RDS " + b.DBInstanceClass
+	}
+	return b.PriceKey().String()
+}
+
 type ReportDaily struct {
-	Regions map[string]ReportByRegion
-	Cost    float64
-	Date    string
+	Regions              map[string]ReportByRegion
+	Accounts             map[string]ReportByAccount
+	Transitions          []ReportTransition
+	IncompleteSamples    []ReportIncompleteSample
+	Cost                 float64
+	InstanceCost         float64
+	VolumeCost           float64
+	UnattachedVolumeCost float64
+	NetworkCost          float64
+	ElasticIPCost        float64
+	NatGatewayCost       float64
+	LoadBalancerCost     float64
+	IdleNetworkCost      float64
+	DBCost               float64
+	Date                 string
+	// Reserved Instances and Savings Plans reduce the on-demand InstanceCost to EffectiveInstanceCost
+	Reservations          []ReportReservation
+	CoveredHours          float64
+	UncoveredHours        float64
+	EffectiveInstanceCost float64
+	// EffectiveCost is what is paid after reservations, price overrides and discounts, Cost is at list price
+	EffectiveCost float64
+	Currency      string
+	// Billed hours of resources no price is known for, they are left out of every cost
+	UnknownCostHours      float64
+	UnpricedInstanceTypes []string
+	// PricingMode is how instances were priced, PriceListVersions the versions of the price list used
+	PricingMode       string
+	PriceListVersions []string
 }
 
 func (r ReportDaily) String() string {
@@ -82,14 +414,28 @@ func (r ReportDaily) String() string {
 	for region, reportByRegion := range r.Regions {
 		s = s + fmt.Sprintf("\n\t%s, Cost: %.2f", region, reportByRegion.Cost)
 		for instanceType, reportInstanceType := range reportByRegion.InstanceTypes {
-			s = s + fmt.Sprintf("\n\t\t%s: Cost: %.2f, Hours:%d", instanceType, reportInstanceType.Cost, reportInstanceType.Hours)
+			s = s + fmt.Sprintf("\n\t\t%s: Cost: %.2f, Hours:%.2f", instanceType, reportInstanceType.Cost, reportInstanceType.Hours)
+		}
+		for _, reportVolumeType := range reportByRegion.VolumeTypes {
+			s = s + fmt.Sprintf("\n\t\t%s", reportVolumeType)
+		}
+		for _, reportNetworkType := range reportByRegion.NetworkTypes {
+			s = s + fmt.Sprintf("\n\t\t%s", reportNetworkType)
+		}
+		for _, reportDBClass := range reportByRegion.DBInstanceClasses {
+			s = s + fmt.Sprintf("\n\t\t%s", reportDBClass)
 		}
 	}
 	return s
 }
 
 func (r ReportDaily) FormatByCost() string {
-	s := fmt.Sprintf("%s, Cost:%.2f", r.Date, r.Cost)
+	s := fmt.Sprintf("%s, Currency:%s, ListCost:%.2f, EffectiveCost:%.2f", r.Date, r.Currency, r.Cost, r.EffectiveCost)
+	s = s + fmt.Sprintf("\n\tPricing:%s, PriceListVersions:%s", r.PricingMode, strings.Join(r.PriceListVersions, ","))
+	s = s + fmt.Sprintf("\n\tInstanceCost:%.2f, VolumeCost:%.2f, UnattachedVolumeCost:%.2f",
+		r.InstanceCost, r.VolumeCost, r.UnattachedVolumeCost)
+	s = s + fmt.Sprintf("\n\tNetworkCost:%.2f, ElasticIPCost:%.2f, NatGatewayCost:%.2f, LoadBalancerCost:%.2f, IdleNetworkCost:%.2f, DBCost:%.2f",
+		r.NetworkCost, r.ElasticIPCost, r.NatGatewayCost, r.LoadBalancerCost, r.IdleNetworkCost, r.DBCost)
 	var regionInfo = make([]ReportByRegion, 0)
 	// Filter and remove regions with no activity
 	for _, reportByRegion := range r.Regions {
@@ -101,19 +447,103 @@ func (r ReportDaily) FormatByCost() string {
 	sort.Slice(regionInfo, func(i, j int) bool { return regionInfo[i].Cost > regionInfo[j].Cost })
 
 	for _, r := range regionInfo {
-		s = s + fmt.Sprintf("\n\t%s, Cost: %.2f", r.Region, r.Cost)
+		s = s + fmt.Sprintf("\n\t%s, Cost: %.2f, EffectiveCost: %.2f, InstanceCost: %.2f, VolumeCost: %.2f, NetworkCost: %.2f, DBCost: %.2f",
+			r.Region, r.Cost, r.EffectiveCost, r.InstanceCost, r.VolumeCost, r.NetworkCost, r.DBCost)
 		for instanceType, reportInstanceType := range r.InstanceTypes {
-			s = s + fmt.Sprintf("\n\t\t%s: Cost: %.2f, Hours:%d, NumberUniqueInstances:%d",
-				instanceType, reportInstanceType.Cost, reportInstanceType.Hours, len(reportInstanceType.UniqueInstances))
+			s = s + fmt.Sprintf("\n\t\t%s: Cost: %.2f, EffectiveCost: %.2f, Hours:%.2f, StoppedHours:%.2f, NumberUniqueInstances:%d",
+				instanceType, reportInstanceType.Cost, reportInstanceType.EffectiveCost, reportInstanceType.Hours, reportInstanceType.StoppedHours, len(reportInstanceType.UniqueInstances))
+			if reportInstanceType.UnpricedHours > 0 {
+				s = s + fmt.Sprintf(", UnknownCostHours:%.2f", reportInstanceType.UnpricedHours)
+			}
+		}
+		for volumeType, reportVolumeType := range r.VolumeTypes {
+			s = s + fmt.Sprintf("\n\t\tEBS %s: Cost: %.2f, Hours:%.2f, NumberUniqueVolumes:%d, UnattachedCost: %.2f",
+				volumeType, reportVolumeType.Cost, reportVolumeType.Hours, len(reportVolumeType.UniqueVolumes), reportVolumeType.UnattachedCost)
+			if reportVolumeType.UnpricedHours > 0 {
+				s = s + fmt.Sprintf(", UnknownCostHours:%.2f", reportVolumeType.UnpricedHours)
+			}
+		}
+		for category, reportNetworkType := range r.NetworkTypes {
+			s = s + fmt.Sprintf("\n\t\t%s: Cost: %.2f, Hours:%.2f, NumberUniqueResources:%d, IdleCost: %.2f",
+				category, reportNetworkType.Cost, reportNetworkType.Hours, len(reportNetworkType.UniqueResources), reportNetworkType.IdleCost)
+			if reportNetworkType.UnpricedHours > 0 {
+				s = s + fmt.Sprintf(", UnknownCostHours:%.2f", reportNetworkType.UnpricedHours)
+			}
+		}
+		for dbInstanceClass, reportDBClass := range r.DBInstanceClasses {
+			s = s + fmt.Sprintf("\n\t\tRDS %s: Cost: %.2f, Hours:%.2f, StoppedHours:%.2f, NumberUniqueInstances:%d",
+				dbInstanceClass, reportDBClass.Cost, reportDBClass.Hours, reportDBClass.StoppedHours, len(reportDBClass.UniqueInstances))
+			if reportDBClass.UnpricedHours > 0 {
+				s = s + fmt.Sprintf(", UnknownCostHours:%.2f", reportDBClass.UnpricedHours)
+			}
+		}
+	}
+	if len(r.Reservations) > 0 {
+		s = s + fmt.Sprintf("\n\tEffectiveInstanceCost:%.2f, CoveredHours:%.2f, UncoveredHours:%.2f",
+			r.EffectiveInstanceCost, r.CoveredHours, r.UncoveredHours)
+		for _, reservation := range r.Reservations {
+			s = s + "\n\t\t" + reservation.String()
+		}
+	}
+	if r.UnknownCostHours > 0 {
+		s = s + fmt.Sprintf("\n\tWARNING: Unknown cost for %.2f hours, no price is known for:", r.UnknownCostHours)
+		for _, k := range r.UnpricedInstanceTypes {
+			s = s + "\n\t\t" + k
+		}
+	}
+	if len(r.IncompleteSamples) > 0 {
+		s = s + "\n\tWARNING: Incomplete coverage, costs are underestimated for:"
+		for _, h := range r.IncompleteSamples {
+			s = s + "\n\t\t" + h.String()
+		}
+	}
+	if len(r.Transitions) > 0 {
+		s = s + "\n\tState changes:"
+		for _, t := range r.Transitions {
+			s = s + "\n\t\t" + t.String()
+		}
+	}
+	accounts := make([]ReportByAccount, 0)
+	for _, reportByAccount := range r.Accounts {
+		if reportByAccount.Cost > 0 {
+			accounts = append(accounts, reportByAccount)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Cost > accounts[j].Cost })
+
+	for _, a := range accounts {
+		s = s + fmt.Sprintf("\n\tAccount %s, Cost: %.2f, EffectiveCost: %.2f", a.Account, a.Cost, a.EffectiveCost)
+		for region, reportByRegion := range a.Regions {
+			if reportByRegion.Cost > 0 {
+				s = s + fmt.Sprintf("\n\t\t%s, Cost: %.2f", region, reportByRegion.Cost)
+			}
 		}
 	}
 	return s
 }
 
+// ReportByAccount has the usage of a single account organized by region
+type ReportByAccount struct {
+	Regions map[string]ReportByRegion
+	Cost    float64
+	// EffectiveCost is after price overrides and the discount of the account, reservations are not included
+	EffectiveCost float64
+	Account       string
+}
+
 type ReportByRegion struct {
-	InstanceTypes map[string]ReportInstanceType
-	Cost          float64
-	Region        string
+	InstanceTypes     map[string]ReportInstanceType
+	VolumeTypes       map[string]ReportVolumeType
+	NetworkTypes      map[string]ReportNetworkType
+	DBInstanceClasses map[string]ReportDBInstanceClass
+	Cost              float64
+	InstanceCost      float64
+	VolumeCost        float64
+	NetworkCost       float64
+	DBCost            float64
+	EffectiveCost     float64
+	UnknownCostHours  float64
+	Region            string
 }
 
 func (r ReportByRegion) String() string {
@@ -124,13 +554,106 @@ func (r ReportByRegion) String() string {
 	return s
 }
 
+// ReportReservation is how much of a Reserved Instance purchase or Savings Plan was used in a day
+type ReportReservation struct {
+	Name         string
+	Type         string
+	Hours        float64
+	CoveredHours float64
+	// Capacity and Used are instance hours for Reserved Instances and dollars of commitment for Savings Plans
+	Capacity    float64
+	Used        float64
+	Utilization float64
+	Cost        float64
+}
+
+func (r ReportReservation) String() string {
+	return fmt.Sprintf("%s %s: Cost: %.2f, Hours:%.2f, CoveredHours:%.2f, Utilization: %.1f%%",
+		r.Type, r.Name, r.Cost, r.Hours, r.CoveredHours, r.Utilization)
+}
+
+// ReportIncompleteSample flags a sample where some regions were not fully collected
+type ReportIncompleteSample struct {
+	Time          time.Time
+	FailedRegions []FailedRegion
+}
+
+func (h ReportIncompleteSample) String() string {
+	s := h.Time.Format("15:04:05")
+	for _, f := range h.FailedRegions {
+		s = s + fmt.Sprintf(" %s %s (%s: %s)", f.AccountID, f.Region, f.Status, f.Error)
+	}
+	return s
+}
+
+// ReportTransition records an instance changing state between two samples
+type ReportTransition struct {
+	Time         time.Time
+	AccountID    string
+	Region       string
+	ID           string
+	InstanceType string
+	From         string
+	To           string
+}
+
+func (t ReportTransition) String() string {
+	return fmt.Sprintf("%s %s %s %s (%s): %s -> %s", t.Time.Format("15:04:05"), t.AccountID, t.Region, t.ID, t.InstanceType, t.From, t.To)
+}
+
 type ReportInstanceType struct {
 	InstanceType    string
-	Hours           int
+	Hours           float64
+	StoppedHours    float64
 	Cost            float64
+	EffectiveCost   float64
+	UnpricedHours   float64
 	UniqueInstances map[string]bool
 }
 
 func (r ReportInstanceType) String() string {
-	return fmt.Sprintf("%s: Cost:%.2f, Hours:%d", r.InstanceType, r.Cost, r.Hours)
+	return fmt.Sprintf("%s: Cost:%.2f, Hours:%.2f", r.InstanceType, r.Cost, r.Hours)
+}
+
+type ReportVolumeType struct {
+	VolumeType     string
+	Hours          float64
+	GBHours        float64
+	Cost           float64
+	UnattachedCost float64
+	UnpricedHours  float64
+	UniqueVolumes  map[string]bool
+}
+
+func (r ReportVolumeType) String() string {
+	return fmt.Sprintf("EBS %s: Cost:%.2f, Hours:%.2f, GBHours:%.2f, UnattachedCost:%.2f", r.VolumeType, r.Cost, r.Hours, r.GBHours, r.UnattachedCost)
+}
+
+// ReportNetworkType tracks the hours and cost of a category of networking resources
+type ReportNetworkType struct {
+	Category        string
+	ResourceType    string
+	Hours           float64
+	Cost            float64
+	IdleCost        float64
+	UnpricedHours   float64
+	UniqueResources map[string]bool
+}
+
+func (r ReportNetworkType) String() string {
+	return fmt.Sprintf("%s: Cost:%.2f, Hours:%.2f, IdleCost:%.2f", r.Category, r.Cost, r.Hours, r.IdleCost)
+}
+
+// ReportDBInstanceClass tracks the hours and cost of RDS DB instances of a class
+type ReportDBInstanceClass struct {
+	DBInstanceClass string
+	Hours           float64
+	StoppedHours    float64
+	Cost            float64
+	UnpricedHours   float64
+	UniqueInstances map[string]bool
+}
+
+func (r ReportDBInstanceClass) String() string {
+	return fmt.Sprintf("RDS %s: Cost:%.2f, Hours:%.2f, StoppedHours:%.2f", r.DBInstanceClass, r.Cost, r.Hours, r.StoppedHours)
 }
@@ -3,9 +3,15 @@ package overlook
 import (
 	log "github.com/sirupsen/logrus"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"time"
 )
 
+// Now returns the time a sample is taken at, replaying a recording pins it to the time of the recording
+var Now = time.Now
+
 // CheckClose used with defer
 func CheckClose(v interface{}) {
 	if d, ok := v.(io.Closer); ok {
@@ -25,3 +31,40 @@ func Exists(name string) bool {
 	}
 	return true
 }
+
+// writeFileAtomic replaces filename with data by writing a temporary file in the same directory and
+// renaming it over filename, readers see either the old or the new content and never a partial file
+func writeFileAtomic(filename string, data []byte) (err error) {
+	dir, base := filepath.Split(filename)
+	tmpFile, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpFile.Name())
+		}
+	}()
+	if _, err = tmpFile.Write(data); err != nil {
+		return err
+	}
+	if err = tmpFile.Sync(); err != nil {
+		return err
+	}
+	if err = tmpFile.Chmod(0660); err != nil {
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpFile.Name(), filename); err != nil {
+		return err
+	}
+	// Persist the rename itself, not every filesystem supports syncing a directory
+	if d, err := os.Open(filepath.Clean(dir)); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package overlook
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetVolumes returns a list of EBS volumes in the region ordered by HoursUp
+func GetVolumes(ctx aws.Context, svc ec2iface.EC2API, region string) ([]VolumeInfo, error) {
+	volumes := make([]VolumeInfo, 0)
+	err := svc.DescribeVolumesPagesWithContext(ctx, &ec2.DescribeVolumesInput{},
+		func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			for _, vol := range page.Volumes {
+				var info VolumeInfo
+				var tags string
+				for _, t := range vol.Tags {
+					tags += fmt.Sprintf("%s:%s ", *t.Key, *t.Value)
+				}
+				info.ID = *vol.VolumeId
+				info.VolumeType = *vol.VolumeType
+				info.SizeGB = *vol.Size
+				if vol.Iops != nil {
+					info.Iops = *vol.Iops
+				}
+				info.State = *vol.State
+				for _, a := range vol.Attachments {
+					if a.InstanceId != nil {
+						info.AttachedTo = *a.InstanceId
+					}
+				}
+				info.AvailabilityZone = *vol.AvailabilityZone
+				info.Region = region
+				info.Tags = tags
+				if vol.CreateTime != nil {
+					info.HoursUp = hoursSince(*vol.CreateTime)
+				}
+				volumes = append(volumes, info)
+			}
+			return true
+		})
+	if err != nil {
+		log.Infoln("Error", err)
+		return nil, err
+	}
+	//
+	// Sort by HoursUp
+	//
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].HoursUp > volumes[j].HoursUp })
+	return volumes, nil
+}
+
+// CalculateVolumeCost calculates cost of current volumes since they were created, volumes no price is
+// known for are left out
+func CalculateVolumeCost(volumes []VolumeInfo) float64 {
+	runningTotal := 0.0
+	for _, vol := range volumes {
+		cost, err := CalculateVolumeCostPer(vol)
+		if err != nil {
+			continue
+		}
+		runningTotal += cost
+	}
+	return runningTotal
+}
+
+// CalculateVolumeCostPer cost of a single volume since it was created
+func CalculateVolumeCostPer(vol VolumeInfo) (float64, error) {
+	cost, err := GetVolumeCostPerHour(vol.Region, vol.VolumeType, vol.SizeGB, vol.Iops)
+	if err != nil {
+		return 0, err
+	}
+	return cost * vol.HoursUp, nil
+}
+
+// CreateVolumeTypeSummary creates summary info on volume types
+func CreateVolumeTypeSummary(volumes []VolumeInfo) map[string]VolumeTypeSummary {
+	var summary = make(map[string]VolumeTypeSummary)
+	for _, vol := range volumes {
+		volSumm := summary[vol.VolumeType]
+		volSumm.VolumeType = vol.VolumeType
+		volSumm.NumberOfVolumes++
+		volSumm.TotalGB += vol.SizeGB
+		if !vol.Attached() {
+			volSumm.NumberUnattached++
+		}
+		costPerHour, err := GetVolumeCostPerHour(vol.Region, vol.VolumeType, vol.SizeGB, vol.Iops)
+		if err != nil {
+			volSumm.NumberUnpriced++
+			summary[vol.VolumeType] = volSumm
+			continue
+		}
+		volSumm.CostPerHour += costPerHour
+		if !vol.Attached() {
+			volSumm.UnattachedCostPerHour += costPerHour
+		}
+		summary[vol.VolumeType] = volSumm
+	}
+	return summary
+}
+
+// FormVolumeSnapshots forms billing snapshots for volumes so they are stored next to instances
+func FormVolumeSnapshots(volumes []VolumeInfo) []BillingSnapshot {
+	var billSnaps = make([]BillingSnapshot, 0)
+	for _, vol := range volumes {
+		b := BillingSnapshot{}
+		costPerHour, err := GetVolumeCostPerHour(vol.Region, vol.VolumeType, vol.SizeGB, vol.Iops)
+		if err != nil {
+			b.Unpriced = true
+		}
+		b.ID = vol.ID
+		b.ResourceType = ResourceTypeVolume
+		b.VolumeType = vol.VolumeType
+		b.SizeGB = vol.SizeGB
+		b.Iops = vol.Iops
+		b.AttachedTo = vol.AttachedTo
+		b.CostPerHour = costPerHour
+		b.CurrentCost = costPerHour * vol.HoursUp
+		b.HoursUp = vol.HoursUp
+		b.Tags = vol.Tags
+		b.State = vol.State
+		b.AvailabilityZone = vol.AvailabilityZone
+		b.Region = vol.Region
+		billSnaps = append(billSnaps, b)
+	}
+	return billSnaps
+}
@@ -0,0 +1,27 @@
+package overlook
+
+import "testing"
+
+func TestVolumeCostLeavesOutUnpricedVolumes(t *testing.T) {
+	volumes := []VolumeInfo{
+		{ID: "vol-priced", VolumeType: "gp2", SizeGB: 100, AttachedTo: "i-1", HoursUp: 10, Region: DefaultRegion},
+		{ID: "vol-unknown", VolumeType: "x9", SizeGB: 100, HoursUp: 10, Region: DefaultRegion},
+		{ID: "vol-elsewhere", VolumeType: "gp2", SizeGB: 100, HoursUp: 10, Region: "eu-west-1"},
+	}
+	costPerHour, err := GetVolumeCostPerHour(DefaultRegion, "gp2", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cost := CalculateVolumeCost(volumes); cost != costPerHour*10 {
+		t.Errorf("expected the priced volume to cost %v, got %v", costPerHour*10, cost)
+	}
+	summary := CreateVolumeTypeSummary(volumes)
+	if gp2 := summary["gp2"]; gp2.NumberOfVolumes != 2 || gp2.NumberUnattached != 1 || gp2.NumberUnpriced != 1 ||
+		gp2.TotalGB != 200 || gp2.CostPerHour != costPerHour || gp2.UnattachedCostPerHour != 0 {
+		t.Errorf("expected both gp2 volumes counted with one unpriced, got %+v", gp2)
+	}
+	if unknown := summary["x9"]; unknown.NumberOfVolumes != 1 || unknown.NumberUnattached != 1 || unknown.NumberUnpriced != 1 {
+		t.Errorf("expected the volume of unknown type counted as unpriced, got %+v", unknown)
+	}
+}